@@ -0,0 +1,49 @@
+package main
+
+import (
+	"io"
+	"os"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/snakelayer/discord-oversessions/owbot/config"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// Sets the output of the logger as configured. The returned Closer must be
+// closed on exit.
+func setLogOutput(logger *logrus.Logger, logConfig config.LogConfig) io.Closer {
+	if logConfig.Output == "stdout" {
+		logger.Out = os.Stdout
+		return nopCloser{}
+	}
+
+	logFile := &lumberjack.Logger{
+		Filename:   logConfig.File,
+		MaxSize:    logConfig.MaxSizeMB,
+		MaxAge:     logConfig.MaxAgeDays,
+		MaxBackups: logConfig.MaxBackups,
+		LocalTime:  true,
+		Compress:   logConfig.Compress,
+	}
+	logger.Out = logFile
+	return logFile
+}
+
+// Sets the level and format of the logger. Unlike the output, these can be
+// changed while running.
+func setLogLevelAndFormat(logger *logrus.Logger, logConfig config.LogConfig) {
+	// the level is known to be valid after config validation
+	logger.Level, _ = logrus.ParseLevel(logConfig.Level)
+
+	if logConfig.Format == "json" {
+		logger.Formatter = &logrus.JSONFormatter{}
+	} else if logConfig.Output == "stdout" {
+		logger.Formatter = &logrus.TextFormatter{}
+	} else {
+		logger.Formatter = &logrus.TextFormatter{DisableColors: true, FullTimestamp: true}
+	}
+}
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
@@ -2,59 +2,56 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"os/signal"
 	"strings"
-	"time"
+	"syscall"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/snakelayer/discord-oversessions/owbot"
+	"github.com/snakelayer/discord-oversessions/owbot/config"
 )
 
 func main() {
-	var (
-		token         string
-		battleTagFile string
-		dbFile        string
-		debug         bool
-	)
-	flag.StringVar(&token, "token", "", "The 	secret token for the bot")
-	flag.StringVar(&battleTagFile, "battleTags", "", "A file mapping discord userIds to battleTags. One entry per line. Space delimited.")
-	flag.StringVar(&dbFile, "dbfile", "", "A path to a file to be used for bolt database")
-	flag.BoolVar(&debug, "debug", false, "Set to true to log debug messages")
+	var configFile string
+	flag.StringVar(&configFile, "config", "", "A YAML config file. Values can be overridden by OVERSESSIONS_* environment variables and flags")
+	flag.String("token", "", "The 	secret token for the bot. Prefer the config file or OVERSESSIONS_TOKEN, as flags are visible to other users")
+	flag.String("battleTags", "", "A file mapping discord userIds to battleTags. One entry per line. Space delimited.")
+	flag.String("dbfile", "", "A path to a file to be used for bolt database")
+	flag.Bool("debug", false, "Set to true to log debug messages")
 	flag.Parse()
 
-	// TODO: This is not a great solution for required config...
-	if token == "" {
-		println("Bot token is required")
-		os.Exit(-1)
-	}
+	loadConfig := func() (*config.Config, error) {
+		cfg, err := config.Load(configFile)
+		if err != nil {
+			return nil, err
+		}
 
-	logger := logrus.New()
-	if debug {
-		logger.Level = logrus.DebugLevel
+		// flags take precedence, but only when explicitly given
+		cfg.ApplyFlags(flag.CommandLine)
+
+		return cfg, cfg.Validate()
 	}
 
-	// reference time: Mon Jan 2 15:04:05 -0700 MST 2006
-	logFileName := time.Now().Format("oversessions.log.2006-01-02-15-04-05")
-	logFileName = strings.Replace(logFileName, " ", "_", -1)
-	logFileName = strings.Replace(logFileName, ":", "", -1)
-	logFile, err := os.OpenFile(logFileName, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	cfg, err := loadConfig()
 	if err != nil {
-		logger.WithFields(logrus.Fields{
-			"module":   "main",
-			"filename": logFileName,
-			"error":    err,
-		}).Fatal("Could not open file for logging")
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
-	defer logFile.Close()
-	logger.Formatter = &logrus.TextFormatter{ForceColors: true}
-	logger.Out = logFile
 
-	var battleTagMap = getBattleTagMapFromFile(logger, battleTagFile)
+	logger := logrus.New()
+	logOutput := setLogOutput(logger, cfg.Log)
+	defer logOutput.Close()
+	setLogLevelAndFormat(logger, cfg.Log)
+
+	battleTagMap, err := getBattleTagMapFromFile(logger, cfg.BattleTagFile)
+	if err != nil {
+		logger.WithField("file name", cfg.BattleTagFile).Fatal("could not read battleTag file")
+	}
 
-	bot, err := owbot.NewBot(logger, token, battleTagMap)
+	bot, err := owbot.NewBot(logger, cfg, battleTagMap)
 	if err != nil {
 		logger.WithFields(logrus.Fields{"module": "main", "error": err}).Error("Could not creating bot")
 		return
@@ -65,24 +62,70 @@ func main() {
 		return
 	}
 
-	// Run until asked to quit
+	reload := func() {
+		nextConfig, err := loadConfig()
+		if err != nil {
+			logger.WithFields(logrus.Fields{"module": "main", "error": err}).Error("Could not reload config, keeping the current one")
+			return
+		}
+
+		battleTagMap, err := getBattleTagMapFromFile(logger, nextConfig.BattleTagFile)
+		if err != nil {
+			logger.WithFields(logrus.Fields{"module": "main", "error": err}).Error("Could not reload battleTag file, keeping the current links")
+			return
+		}
+
+		if nextConfig.Log.Output != cfg.Log.Output || nextConfig.Log.File != cfg.Log.File {
+			logger.WithField("module", "main").Warn("log output changed, restart the bot to apply it")
+			nextConfig.Log.Output = cfg.Log.Output
+		}
+		setLogLevelAndFormat(logger, nextConfig.Log)
+
+		bot.UpdateConfig(nextConfig)
+		bot.UpdateBattleTags(battleTagMap)
+		cfg = nextConfig
+	}
+
+	// Run until asked to quit, reloading on SIGHUP, !reload or when a watched
+	// file changes
 	interruptChan := make(chan os.Signal, 1)
 	signal.Notify(interruptChan, os.Interrupt, os.Kill)
-	<-interruptChan
-
-	bot.Stop()
+	reloadChan := make(chan os.Signal, 1)
+	signal.Notify(reloadChan, syscall.SIGHUP)
+
+	watcher := newFileWatcher(configFile, cfg.BattleTagFile)
+	for {
+		select {
+		case <-interruptChan:
+			bot.Stop()
+			return
+		case <-reloadChan:
+			logger.WithField("module", "main").Info("Reloading on SIGHUP")
+			reload()
+		case <-bot.ReloadRequests():
+			logger.WithField("module", "main").Info("Reloading on !reload")
+			reload()
+		case <-watcher.tick(cfg.Polling.FileWatchInterval):
+			if !watcher.changed() {
+				continue
+			}
+			logger.WithField("module", "main").Info("Reloading on file change")
+			reload()
+		}
+		watcher.setFiles(configFile, cfg.BattleTagFile)
+	}
 }
 
-func getBattleTagMapFromFile(logger *logrus.Logger, file string) map[string]string {
+func getBattleTagMapFromFile(logger *logrus.Logger, file string) (map[string]string, error) {
 	var battleTagMap = make(map[string]string)
 
 	if file == "" {
-		return battleTagMap
+		return battleTagMap, nil
 	}
 
 	battleTagData, err := ioutil.ReadFile(file)
 	if err != nil {
-		logger.WithField("file name", file).Fatal("could not read battleTag file")
+		return nil, err
 	}
 
 	entries := strings.Split(string(battleTagData), "\n")
@@ -102,5 +145,5 @@ func getBattleTagMapFromFile(logger *logrus.Logger, file string) map[string]stri
 		battleTagMap[userId] = battleTag
 	}
 
-	return battleTagMap
+	return battleTagMap, nil
 }
@@ -0,0 +1,126 @@
+package owbot
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	altUsage = "usage: `!alt [add|remove|primary <battleTag>]`"
+
+	// Most alt accounts of a user, as the stats of every account are
+	// fetched at the start and end of each session
+	maxAlts = 5
+)
+
+// Handles the !alt command, listing the author's accounts, adding or
+// removing an alt account, or making an alt account the primary one
+func (bot *Bot) altCommand(message *discordgo.Message, args string) {
+	user := message.Author
+	bot.logger.WithField("user", user).WithField("args", args).Info("alt request")
+
+	link, err := bot.store.GetLink(user.ID)
+	if err != nil {
+		bot.logger.WithError(err).WithField("userId", user.ID).Error("could not get link")
+		bot.reply(message, "could not get your accounts, please try again")
+		return
+	}
+	if link == nil {
+		bot.reply(message, user.Username+" is not linked, use `!link <battleTag>` first")
+		return
+	}
+
+	input := strings.Fields(args)
+	if len(input) == 0 {
+		content := user.Username + ": primary " + link.BattleTag
+		if len(link.Alts) > 0 {
+			content += ", alts " + strings.Join(link.Alts, ", ")
+		}
+		bot.reply(message, content)
+		return
+	}
+	if len(input) != 2 {
+		bot.reply(message, altUsage)
+		return
+	}
+
+	battleTag := input[1]
+	isAlt := containsString(link.Alts, battleTag)
+	switch input[0] {
+	case "add":
+		if battleTag == link.BattleTag || isAlt {
+			bot.reply(message, battleTag+" is already one of "+user.Username+"'s accounts")
+			return
+		}
+		if len(link.Alts) >= maxAlts {
+			bot.reply(message, fmt.Sprintf("remove an alt account first, there can be at most %d", maxAlts))
+			return
+		}
+		if bot.getConfig().Guild.VerifyLinks {
+			bot.startLinkVerification(message, battleTag, true)
+			return
+		}
+		if _, ok := bot.getLinkableBlob(battleTag); !ok {
+			return
+		}
+		if err := bot.addPlayerAlt(user.ID, battleTag); err != nil {
+			bot.logger.WithError(err).WithField("userId", user.ID).Error("could not store alt account")
+			bot.reply(message, "could not add "+battleTag+" to "+user.Username+"'s accounts")
+			return
+		}
+		bot.reply(message, battleTag+" is added as an alt account of "+user.Username)
+	case "remove":
+		if !isAlt {
+			bot.reply(message, battleTag+" is not an alt account of "+user.Username)
+			return
+		}
+		if err := bot.setPlayerAccounts(user.ID, link.BattleTag, removeBattleTag(link.Alts, battleTag)); err != nil {
+			bot.logger.WithError(err).WithField("userId", user.ID).Error("could not store accounts")
+			bot.reply(message, "could not remove "+battleTag+" from "+user.Username+"'s accounts")
+			return
+		}
+		bot.reply(message, battleTag+" is no longer an alt account of "+user.Username)
+	case "primary":
+		if !isAlt {
+			bot.reply(message, battleTag+" is not an alt account of "+user.Username)
+			return
+		}
+		alts := append(removeBattleTag(link.Alts, battleTag), link.BattleTag)
+		if err := bot.setPlayerAccounts(user.ID, battleTag, alts); err != nil {
+			bot.logger.WithError(err).WithField("userId", user.ID).Error("could not store accounts")
+			bot.reply(message, "could not make "+battleTag+" "+user.Username+"'s primary account")
+			return
+		}
+		bot.reply(message, battleTag+" is now "+user.Username+"'s primary account, and "+link.BattleTag+" an alt account")
+	default:
+		bot.reply(message, altUsage)
+	}
+}
+
+// Adds battleTag to the alt accounts of the linked user
+func (bot *Bot) addPlayerAlt(userId string, battleTag string) error {
+	link, err := bot.store.GetLink(userId)
+	if err != nil {
+		return err
+	}
+	if link == nil {
+		return errors.New("user is not linked")
+	}
+	if battleTag == link.BattleTag || containsString(link.Alts, battleTag) {
+		return nil
+	}
+
+	return bot.setPlayerAccounts(userId, link.BattleTag, append(link.Alts, battleTag))
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
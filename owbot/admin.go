@@ -0,0 +1,211 @@
+package owbot
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	linkUsage       = "usage: `!link [@user] <battleTag>`"
+	unlinkUsage     = "usage: `!unlink [@user]`"
+	setChannelUsage = "usage: `!setchannel [#channel|reset]`"
+
+	// Longest config shown in a message; longer configs are attached as a file
+	maxConfigMessageLength = 1900
+)
+
+// A channel mention as written in a message
+var regexChannelMention = regexp.MustCompile(`^<#(\d+)>$`)
+
+// Returns the user mentioned by arg, if arg is a mention of a user in the
+// message
+func getMentionedUser(message *discordgo.Message, arg string) (*discordgo.User, bool) {
+	match := regexUserMention.FindStringSubmatch(arg)
+	if match == nil {
+		return nil, false
+	}
+	for _, user := range message.Mentions {
+		if user.ID == match[1] {
+			return user, true
+		}
+	}
+	return nil, false
+}
+
+// Handles the !link command, linking the author or, for moderators, the
+// mentioned user to a battleTag. Users linking themselves, also by
+// mentioning themselves, are verified first if guild.verifyLinks is set.
+func (bot *Bot) linkCommand(message *discordgo.Message, args string) {
+	input := strings.Fields(args)
+	switch len(input) {
+	case 1:
+		bot.linkAuthor(message, input[0])
+	case 2:
+		user, ok := getMentionedUser(message, input[0])
+		if !ok {
+			bot.reply(message, linkUsage)
+			return
+		}
+		if user.ID == message.Author.ID {
+			bot.linkAuthor(message, input[1])
+			return
+		}
+		if !bot.hasPermission(message, actionLinkOthers) {
+			return
+		}
+		bot.linkPlayerBattleTag(user, input[1])
+	default:
+		bot.reply(message, linkUsage)
+	}
+}
+
+// Links the author to the battleTag, or starts verifying that it is theirs
+// if guild.verifyLinks is set
+func (bot *Bot) linkAuthor(message *discordgo.Message, battleTag string) {
+	if bot.getConfig().Guild.VerifyLinks {
+		bot.startLinkVerification(message, battleTag, false)
+		return
+	}
+	bot.linkPlayerBattleTag(message.Author, battleTag)
+}
+
+// Handles the !unlink command, unlinking the author or, for moderators, the
+// mentioned user
+func (bot *Bot) unlinkCommand(message *discordgo.Message, args string) {
+	args = strings.TrimSpace(args)
+	if args == "" {
+		bot.unlinkPlayerBattleTag(message.Author)
+		return
+	}
+
+	user, ok := getMentionedUser(message, args)
+	if !ok {
+		bot.reply(message, unlinkUsage)
+		return
+	}
+	if user.ID != message.Author.ID && !bot.hasPermission(message, actionUnlinkOthers) {
+		return
+	}
+	if _, ok := bot.getPlayerState(user.ID); !ok {
+		bot.reply(message, user.Username+" is not linked to a battleTag")
+		return
+	}
+	bot.unlinkPlayerBattleTag(user)
+}
+
+// Handles the !setchannel command, which makes the channel the command is
+// sent in, or the mentioned channel, the channel used for reports and
+// commands. "reset" goes back to finding the channel by guild.channelPattern.
+func (bot *Bot) setChannelCommand(message *discordgo.Message, args string) {
+	bot.logger.WithField("user", message.Author).WithField("args", args).Info("set channel request")
+
+	if !bot.hasPermission(message, actionSetChannel) {
+		return
+	}
+
+	channelId := message.ChannelID
+	if args = strings.TrimSpace(args); args == "reset" {
+		channelId = ""
+	} else if args != "" {
+		match := regexChannelMention.FindStringSubmatch(args)
+		if match == nil {
+			bot.reply(message, setChannelUsage)
+			return
+		}
+		channelId = match[1]
+	}
+
+	guildId := bot.discord.GetGuildId()
+	settings, err := bot.store.GetGuildSettings(guildId)
+	if err != nil {
+		bot.logger.WithError(err).WithField("guildId", guildId).Error("could not get guild settings")
+		bot.reply(message, "could not change the channel")
+		return
+	}
+
+	if err := bot.discord.SetOverwatchChannel(channelId); err != nil {
+		bot.logger.WithError(err).WithField("channelId", channelId).Info("invalid channel")
+		bot.reply(message, "that is not a text channel of this guild")
+		return
+	}
+	settings.ChannelId = channelId
+	if err := bot.store.PutGuildSettings(guildId, settings); err != nil {
+		bot.logger.WithError(err).WithField("guildId", guildId).Error("could not store guild settings")
+		bot.reply(message, "the channel is changed until the bot restarts, as it could not be saved")
+		return
+	}
+
+	content := "reports and commands now use <#" + bot.discord.GetOverwatchChannelId() + ">"
+	if channelId == "" {
+		content += ", found by the channel pattern"
+	}
+	bot.reply(message, content)
+}
+
+// Uses the channel picked with !setchannel, if any. Called once the guild
+// is known.
+func (bot *Bot) applyGuildChannel() {
+	guildId := bot.discord.GetGuildId()
+	settings, err := bot.store.GetGuildSettings(guildId)
+	if err != nil {
+		bot.logger.WithError(err).WithField("guildId", guildId).Error("could not get guild settings")
+		return
+	}
+	if settings.ChannelId == "" {
+		return
+	}
+
+	if err := bot.discord.SetOverwatchChannel(settings.ChannelId); err != nil {
+		bot.logger.WithError(err).WithField("channelId", settings.ChannelId).Warn("picked channel not found, using the channel pattern")
+	}
+}
+
+// Handles the !config command, showing the running config without its
+// secrets
+func (bot *Bot) configCommand(message *discordgo.Message) {
+	bot.logger.WithField("user", message.Author).Info("config request")
+
+	if !bot.hasPermission(message, actionShowConfig) {
+		return
+	}
+
+	data, err := yaml.Marshal(bot.getConfig().Redacted())
+	if err != nil {
+		bot.logger.WithError(err).Error("could not marshal config")
+		bot.reply(message, "could not show the config")
+		return
+	}
+
+	if len(data) > maxConfigMessageLength {
+		bot.replyWithFile(message, "running config:", "config.yaml", bytes.NewReader(data))
+		return
+	}
+	bot.reply(message, "running config:\n```yaml\n"+string(data)+"```")
+}
+
+// Handles the !reload command, reloading the config and battleTag files
+// like SIGHUP does
+func (bot *Bot) reloadCommand(message *discordgo.Message) {
+	bot.logger.WithField("user", message.Author).Info("reload request")
+
+	if !bot.hasPermission(message, actionReload) {
+		return
+	}
+
+	select {
+	case bot.reloadRequests <- struct{}{}:
+		bot.reply(message, "reloading the config and battleTag files")
+	default:
+		bot.reply(message, "a reload is already pending")
+	}
+}
+
+// Returns the channel receiving a value whenever a reload was requested
+// with !reload
+func (bot *Bot) ReloadRequests() <-chan struct{} {
+	return bot.reloadRequests
+}
@@ -0,0 +1,265 @@
+package owbot
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/snakelayer/discord-oversessions/owbot/player"
+	"github.com/snakelayer/discord-oversessions/owbot/store"
+)
+
+// The admin api, served under /api/ when an admin token is configured.
+// Every request must carry the token as "Authorization: Bearer <token>".
+//
+//	GET    /api/links                      list links
+//	PUT    /api/links/<userId>             link a user, body {"battleTag": "name#1234"}
+//	DELETE /api/links/<userId>             unlink a user
+//	GET    /api/players                    list live player states
+//	GET    /api/players/<userId>           get a live player state
+//	POST   /api/players/<userId>/report    post a report of the player's stats now
+//	POST   /api/players/<userId>/refresh   refresh the stats of a player not in a session
+//	POST   /api/refresh                    refresh the stats of all players not in a session
+//	GET    /api/reports                    list session reports waiting for stats
+func (bot *Bot) registerAPIHandlers(mux *http.ServeMux, adminToken string) {
+	mux.Handle("/api/links", bot.requireAdminToken(adminToken, bot.linksHandler))
+	mux.Handle("/api/links/", bot.requireAdminToken(adminToken, bot.linkHandler))
+	mux.Handle("/api/players", bot.requireAdminToken(adminToken, bot.playersHandler))
+	mux.Handle("/api/players/", bot.requireAdminToken(adminToken, bot.playerHandler))
+	mux.Handle("/api/refresh", bot.requireAdminToken(adminToken, bot.refreshHandler))
+	mux.Handle("/api/reports", bot.requireAdminToken(adminToken, bot.reportsHandler))
+}
+
+func (bot *Bot) requireAdminToken(adminToken string, handler http.HandlerFunc) http.Handler {
+	expected := []byte("Bearer " + adminToken)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actual := []byte(r.Header.Get("Authorization"))
+		if subtle.ConstantTimeCompare(actual, expected) != 1 {
+			writeAPIError(w, http.StatusUnauthorized, errors.New("invalid admin token"))
+			return
+		}
+
+		bot.logger.WithField("method", r.Method).WithField("path", r.URL.Path).Info("admin api request")
+		handler(w, r)
+	})
+}
+
+// A player state as returned by the api
+type playerView struct {
+	UserId    string    `json:"userId"`
+	Username  string    `json:"username,omitempty"`
+	BattleTag string    `json:"battleTag"`
+	Playing   bool      `json:"playing"`
+	Timestamp time.Time `json:"timestamp"`
+	HasStats  bool      `json:"hasStats"`
+	CompRank  int       `json:"compRank"`
+}
+
+func newPlayerView(userId string, playerState player.PlayerState) playerView {
+	view := playerView{
+		UserId:    userId,
+		BattleTag: playerState.BattleTag,
+		Playing:   playerState.Game != nil,
+		Timestamp: playerState.Timestamp,
+	}
+	if playerState.User != nil {
+		view.Username = playerState.User.Username
+	}
+	if playerState.RegionBlob != nil {
+		view.HasStats = true
+		view.CompRank = playerState.RegionBlob.GetCompRank()
+	}
+
+	return view
+}
+
+func (bot *Bot) linksHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeAPIError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+
+	links, err := bot.store.GetLinks()
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	linkList := make([]store.Link, 0, len(links))
+	for _, link := range links {
+		linkList = append(linkList, link)
+	}
+	sort.Slice(linkList, func(i, j int) bool {
+		return linkList[i].UserId < linkList[j].UserId
+	})
+
+	writeAPIResponse(w, http.StatusOK, linkList)
+}
+
+func (bot *Bot) linkHandler(w http.ResponseWriter, r *http.Request) {
+	userId := strings.TrimPrefix(r.URL.Path, "/api/links/")
+	if userId == "" || strings.Contains(userId, "/") {
+		writeAPIError(w, http.StatusNotFound, errors.New("not found"))
+		return
+	}
+
+	switch r.Method {
+	case "PUT":
+		var body struct {
+			BattleTag string `json:"battleTag"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeAPIError(w, http.StatusBadRequest, err)
+			return
+		}
+		if !regexBattleTag.MatchString(body.BattleTag) {
+			writeAPIError(w, http.StatusBadRequest, errors.New(body.BattleTag+" is not a valid battleTag"))
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), bot.getConfig().Polling.LongCommandTimeout)
+		defer cancel()
+		if _, err := bot.overwatch.GetUSPlayerBlob(ctx, body.BattleTag); err != nil {
+			writeAPIError(w, http.StatusBadRequest, errors.New(body.BattleTag+" is not a valid Overwatch account"))
+			return
+		}
+
+		if err := bot.linkPlayer(userId, body.BattleTag, store.LinkSourceAPI); err != nil {
+			writeAPIError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeAPIResponse(w, http.StatusOK, store.Link{UserId: userId, BattleTag: body.BattleTag, Source: store.LinkSourceAPI})
+	case "DELETE":
+		if err := bot.unlinkPlayer(userId); err != nil {
+			writeAPIError(w, http.StatusInternalServerError, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeAPIError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+	}
+}
+
+func (bot *Bot) playersHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeAPIError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+
+	var views []playerView
+	for userId, playerState := range bot.getPlayerStates() {
+		views = append(views, newPlayerView(userId, playerState))
+	}
+	sort.Slice(views, func(i, j int) bool {
+		return views[i].UserId < views[j].UserId
+	})
+
+	writeAPIResponse(w, http.StatusOK, views)
+}
+
+func (bot *Bot) playerHandler(w http.ResponseWriter, r *http.Request) {
+	path := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/players/"), "/")
+	userId := path[0]
+	playerState, ok := bot.getPlayerState(userId)
+	if !ok || len(path) > 2 {
+		writeAPIError(w, http.StatusNotFound, errors.New("not found"))
+		return
+	}
+
+	action := ""
+	if len(path) == 2 {
+		action = path[1]
+	}
+
+	switch {
+	case action == "" && r.Method == "GET":
+		writeAPIResponse(w, http.StatusOK, newPlayerView(userId, playerState))
+	case action == "report" && r.Method == "POST":
+		if err := bot.forceSessionReport(userId); err != nil {
+			writeAPIError(w, http.StatusBadGateway, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case action == "refresh" && r.Method == "POST":
+		if err := bot.refreshPlayerStats(userId); err != nil {
+			writeAPIError(w, http.StatusConflict, err)
+			return
+		}
+		playerState, _ = bot.getPlayerState(userId)
+		writeAPIResponse(w, http.StatusOK, newPlayerView(userId, playerState))
+	default:
+		writeAPIError(w, http.StatusNotFound, errors.New("not found"))
+	}
+}
+
+func (bot *Bot) refreshHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		writeAPIError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+
+	refreshed := make(map[string]string)
+	for userId := range bot.getPlayerStates() {
+		if err := bot.refreshPlayerStats(userId); err != nil {
+			refreshed[userId] = err.Error()
+		} else {
+			refreshed[userId] = "ok"
+		}
+	}
+
+	writeAPIResponse(w, http.StatusOK, refreshed)
+}
+
+func (bot *Bot) reportsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeAPIError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+
+	writeAPIResponse(w, http.StatusOK, bot.getPendingReports())
+}
+
+// Fetches the current stats of a player. Players in a session are skipped,
+// as their stats from the start of the session are needed for the report.
+func (bot *Bot) refreshPlayerStats(userId string) error {
+	lockedPlayerState, ok := bot.getPlayerState(userId)
+	if !ok {
+		return errors.New("player is not linked")
+	}
+
+	lockedPlayerState.UpdateMutex.Lock()
+	defer lockedPlayerState.UpdateMutex.Unlock()
+
+	// the state may have been replaced or removed by a reload while waiting for the lock
+	playerState, ok := bot.getPlayerState(userId)
+	if !ok {
+		return errors.New("player is not linked")
+	}
+	if playerState.UpdateMutex != lockedPlayerState.UpdateMutex {
+		return errors.New("player was relinked, try again")
+	}
+	if playerState.Game != nil {
+		return errors.New("player is in a session")
+	}
+
+	if err := bot.setPlayerBlob(&playerState); err != nil {
+		return err
+	}
+	bot.setPlayerState(userId, playerState)
+	return nil
+}
+
+func writeAPIResponse(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeAPIError(w http.ResponseWriter, status int, err error) {
+	writeAPIResponse(w, status, map[string]string{"error": err.Error()})
+}
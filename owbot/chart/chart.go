@@ -0,0 +1,284 @@
+// Package chart renders SR charts as PNG images, without any dependencies
+// beyond the standard library.
+package chart
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"time"
+)
+
+// Session is the SR change and comp results of one session
+type Session struct {
+	Start     time.Time
+	End       time.Time
+	InitialSR int
+	FinalSR   int
+	Wins      int
+	Losses    int
+	// Sessions of different seasons are not connected
+	Season int
+}
+
+const (
+	chartWidth  = 800
+	chartHeight = 360
+
+	marginLeft   = 56
+	marginRight  = 16
+	marginTop    = 16
+	marginBottom = 72
+
+	// height of the win/loss bars below the SR line
+	resultsHeight = 32
+)
+
+var (
+	colorBackground = color.RGBA{0x36, 0x39, 0x3f, 0xff}
+	colorGrid       = color.RGBA{0x4f, 0x54, 0x5c, 0xff}
+	colorBoundary   = color.RGBA{0x42, 0x45, 0x4c, 0xff}
+	colorSeason     = color.RGBA{0x8e, 0x92, 0x97, 0xff}
+	colorLabel      = color.RGBA{0xb9, 0xbb, 0xbe, 0xff}
+	colorLine       = color.RGBA{0xf9, 0x9e, 0x1a, 0xff}
+	colorWin        = color.RGBA{0x43, 0xb5, 0x81, 0xff}
+	colorLoss       = color.RGBA{0xf0, 0x47, 0x47, 0xff}
+	colorEven       = color.RGBA{0xdc, 0xdd, 0xde, 0xff}
+)
+
+var ErrNoSessions = errors.New("no ranked sessions to chart")
+
+// Writes a PNG line chart of the SR over the sessions. Each session gets an
+// equal share of the width, separated by a boundary line; within it the SR
+// goes from its initial to its final value, and the end point is colored by
+// whether the session was won or lost overall. A new season starts at a
+// brighter boundary, with no line from the previous session. The comp wins
+// and losses of each session are drawn as bars below the chart. Sessions
+// without an SR are skipped.
+func RenderSRChart(w io.Writer, sessions []Session) error {
+	ranked := rankedSessions(sessions)
+	if len(ranked) == 0 {
+		return ErrNoSessions
+	}
+
+	img := newImage(chartWidth, chartHeight)
+	plot := image.Rect(marginLeft, marginTop, chartWidth-marginRight, chartHeight-marginBottom)
+
+	minSR, maxSR := srRange(ranked)
+	step := gridStep(maxSR - minSR)
+	minSR = minSR / step * step
+	maxSR = (maxSR + step - 1) / step * step
+	if minSR == maxSR {
+		maxSR += step
+	}
+	y := func(sr int) int {
+		return plot.Max.Y - (sr-minSR)*plot.Dy()/(maxSR-minSR)
+	}
+
+	for sr := minSR; sr <= maxSR; sr += step {
+		drawLine(img, plot.Min.X, y(sr), plot.Max.X, y(sr), colorGrid, 1)
+		label := itoa(sr)
+		drawText(img, plot.Min.X-8-textWidth(label, 2), y(sr)-5, label, colorLabel, 2)
+	}
+
+	slot := float64(plot.Dx()) / float64(len(ranked))
+	x := func(i int) int {
+		return plot.Min.X + int(float64(i)*slot)
+	}
+
+	maxGames := 1
+	for _, session := range ranked {
+		if session.Wins > maxGames {
+			maxGames = session.Wins
+		}
+		if session.Losses > maxGames {
+			maxGames = session.Losses
+		}
+	}
+	resultsBase := plot.Max.Y + 8 + resultsHeight
+
+	for i, session := range ranked {
+		newSeason := i > 0 && session.Season != ranked[i-1].Season
+		if newSeason {
+			drawLine(img, x(i), plot.Min.Y, x(i), plot.Max.Y, colorSeason, 1)
+		} else if i > 0 {
+			drawLine(img, x(i), plot.Min.Y, x(i), plot.Max.Y, colorBoundary, 1)
+		}
+
+		// the session runs over the middle of its slot
+		startX, endX := x(i)+int(slot/4), x(i)+int(slot*3/4)
+		if i > 0 && !newSeason {
+			prevEndX := x(i-1) + int(slot*3/4)
+			drawLine(img, prevEndX, y(ranked[i-1].FinalSR), startX, y(session.InitialSR), colorLine, 1)
+		}
+		drawLine(img, startX, y(session.InitialSR), endX, y(session.FinalSR), colorLine, 3)
+		fillRect(img, image.Rect(endX-3, y(session.FinalSR)-3, endX+4, y(session.FinalSR)+4), resultColor(session))
+
+		barWidth := int(slot / 4)
+		if barWidth < 1 {
+			barWidth = 1
+		}
+		middle := x(i) + int(slot/2)
+		winHeight := session.Wins * resultsHeight / maxGames
+		lossHeight := session.Losses * resultsHeight / maxGames
+		fillRect(img, image.Rect(middle-barWidth, resultsBase-winHeight, middle, resultsBase), colorWin)
+		fillRect(img, image.Rect(middle, resultsBase-lossHeight, middle+barWidth, resultsBase), colorLoss)
+	}
+
+	first, last := dateLabel(ranked[0].Start), dateLabel(ranked[len(ranked)-1].End)
+	drawText(img, plot.Min.X, chartHeight-18, first, colorLabel, 2)
+	drawText(img, plot.Max.X-textWidth(last, 2), chartHeight-18, last, colorLabel, 2)
+
+	return png.Encode(w, img)
+}
+
+const (
+	sparklineWidth  = 160
+	sparklineHeight = 32
+)
+
+// Writes a small PNG of the SR over the sessions, without any labels, for
+// use alongside a session report. The last point is colored by the SR
+// change of the last session.
+func RenderSparkline(w io.Writer, sessions []Session) error {
+	ranked := rankedSessions(sessions)
+	if len(ranked) == 0 {
+		return ErrNoSessions
+	}
+
+	srs := []int{ranked[0].InitialSR}
+	for _, session := range ranked {
+		srs = append(srs, session.FinalSR)
+	}
+
+	img := newImage(sparklineWidth, sparklineHeight)
+	plot := image.Rect(4, 4, sparklineWidth-4, sparklineHeight-4)
+
+	minSR, maxSR := srRange(ranked)
+	if minSR == maxSR {
+		minSR, maxSR = minSR-1, maxSR+1
+	}
+	point := func(i int) (int, int) {
+		return plot.Min.X + i*plot.Dx()/(len(srs)-1),
+			plot.Max.Y - (srs[i]-minSR)*plot.Dy()/(maxSR-minSR)
+	}
+
+	for i := 1; i < len(srs); i++ {
+		x0, y0 := point(i - 1)
+		x1, y1 := point(i)
+		drawLine(img, x0, y0, x1, y1, colorLine, 2)
+	}
+	lastX, lastY := point(len(srs) - 1)
+	last := ranked[len(ranked)-1]
+	markerColor := colorEven
+	if last.FinalSR > last.InitialSR {
+		markerColor = colorWin
+	} else if last.FinalSR < last.InitialSR {
+		markerColor = colorLoss
+	}
+	fillRect(img, image.Rect(lastX-2, lastY-2, lastX+3, lastY+3), markerColor)
+
+	return png.Encode(w, img)
+}
+
+func rankedSessions(sessions []Session) []Session {
+	var ranked []Session
+	for _, session := range sessions {
+		if session.InitialSR != 0 && session.FinalSR != 0 {
+			ranked = append(ranked, session)
+		}
+	}
+
+	return ranked
+}
+
+func srRange(sessions []Session) (int, int) {
+	minSR, maxSR := sessions[0].InitialSR, sessions[0].InitialSR
+	for _, session := range sessions {
+		for _, sr := range []int{session.InitialSR, session.FinalSR} {
+			if sr < minSR {
+				minSR = sr
+			}
+			if sr > maxSR {
+				maxSR = sr
+			}
+		}
+	}
+
+	return minSR, maxSR
+}
+
+// Picks the SR between grid lines so that there are at most 8 of them
+func gridStep(srRange int) int {
+	for _, step := range []int{25, 50, 100, 250, 500} {
+		if srRange/step < 8 {
+			return step
+		}
+	}
+
+	return 1000
+}
+
+func resultColor(session Session) color.Color {
+	if session.Wins > session.Losses {
+		return colorWin
+	} else if session.Losses > session.Wins {
+		return colorLoss
+	}
+	return colorEven
+}
+
+func dateLabel(t time.Time) string {
+	return itoa(int(t.Month())) + "/" + itoa(t.Day())
+}
+
+func newImage(width int, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), &image.Uniform{colorBackground}, image.ZP, draw.Src)
+
+	return img
+}
+
+func fillRect(img *image.RGBA, rect image.Rectangle, c color.Color) {
+	draw.Draw(img, rect, &image.Uniform{c}, image.ZP, draw.Src)
+}
+
+// Draws a line of the given thickness with Bresenham's algorithm
+func drawLine(img *image.RGBA, x0 int, y0 int, x1 int, y1 int, c color.Color, thickness int) {
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+
+	offset := thickness / 2
+	err := dx + dy
+	for {
+		fillRect(img, image.Rect(x0-offset, y0-offset, x0-offset+thickness, y0-offset+thickness), c)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		e2 := 2 * err
+		if e2 >= dy {
+			err += dy
+			x0 += sx
+		}
+		if e2 <= dx {
+			err += dx
+			y0 += sy
+		}
+	}
+}
+
+func abs(value int) int {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
@@ -0,0 +1,56 @@
+package chart
+
+import (
+	"image"
+	"image/color"
+	"strconv"
+)
+
+const (
+	glyphWidth  = 3
+	glyphHeight = 5
+)
+
+// A 3x5 pixel font covering what the axis labels need. Each row is 3 bits,
+// the leftmost pixel being the highest bit.
+var glyphs = map[rune][glyphHeight]uint8{
+	'0': {7, 5, 5, 5, 7},
+	'1': {2, 6, 2, 2, 7},
+	'2': {7, 1, 7, 4, 7},
+	'3': {7, 1, 7, 1, 7},
+	'4': {5, 5, 7, 1, 1},
+	'5': {7, 4, 7, 1, 7},
+	'6': {7, 4, 7, 5, 7},
+	'7': {7, 1, 1, 2, 2},
+	'8': {7, 5, 7, 5, 7},
+	'9': {7, 5, 7, 1, 7},
+	'/': {1, 1, 2, 4, 4},
+	'-': {0, 0, 7, 0, 0},
+	'+': {0, 2, 7, 2, 0},
+}
+
+// Draws text with its top left corner at x, y, each font pixel drawn as a
+// scale by scale square. Characters missing from the font are left blank.
+func drawText(img *image.RGBA, x int, y int, text string, c color.Color, scale int) {
+	for _, char := range text {
+		glyph := glyphs[char]
+		for row := 0; row < glyphHeight; row++ {
+			for column := 0; column < glyphWidth; column++ {
+				if glyph[row]&(1<<uint(glyphWidth-1-column)) == 0 {
+					continue
+				}
+				px, py := x+column*scale, y+row*scale
+				fillRect(img, image.Rect(px, py, px+scale, py+scale), c)
+			}
+		}
+		x += (glyphWidth + 1) * scale
+	}
+}
+
+func textWidth(text string, scale int) int {
+	return (len(text)*(glyphWidth+1) - 1) * scale
+}
+
+func itoa(value int) string {
+	return strconv.Itoa(value)
+}
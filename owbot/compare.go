@@ -0,0 +1,195 @@
+package owbot
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/snakelayer/discord-oversessions/owbot/overwatch"
+	"github.com/snakelayer/discord-oversessions/owbot/store"
+)
+
+const (
+	compareUsage = "usage: `!compare @user [@user]`"
+
+	// Heroes listed as a player's top heroes and as heroes both play
+	compareHeroes = 3
+)
+
+// Handles the !compare command, comparing the stats of two players side by
+// side. With one mention, the author is compared to the mentioned user.
+func (bot *Bot) compareCommand(message *discordgo.Message, args string) {
+	bot.logger.WithField("user", message.Author).WithField("args", args).Info("compare request")
+
+	var userIds []string
+	for _, arg := range strings.Fields(args) {
+		match := regexUserMention.FindStringSubmatch(arg)
+		if match == nil {
+			bot.reply(message, compareUsage)
+			return
+		}
+		userIds = append(userIds, match[1])
+	}
+	if len(userIds) == 1 {
+		userIds = []string{message.Author.ID, userIds[0]}
+	}
+	if len(userIds) != 2 {
+		bot.reply(message, compareUsage)
+		return
+	}
+	if userIds[0] == userIds[1] {
+		bot.reply(message, "pick two different players to compare")
+		return
+	}
+
+	names := bot.getPlayerNames()
+	var blobs [2]*overwatch.RegionBlob
+	var srs [2]string
+	for i, userId := range userIds {
+		playerState, ok := bot.getPlayerState(userId)
+		if !ok {
+			bot.reply(message, "that user is not linked to a battleTag")
+			return
+		}
+		if !bot.canShowPlayer(message, userId, false) {
+			return
+		}
+		if playerState.RegionBlob == nil {
+			bot.reply(message, "no stats known for "+playerState.BattleTag+" yet")
+			return
+		}
+		blobs[i] = playerState.RegionBlob
+
+		srs[i] = "hidden"
+		if !bot.getPrivacy(userId).HideSR() {
+			srs[i] = formatCompareSR(playerState.RegionBlob.GetCompRank())
+		}
+	}
+
+	var buffer bytes.Buffer
+	fmt.Fprintf(&buffer, "**%s** vs **%s**", names[userIds[0]], names[userIds[1]])
+	fmt.Fprintf(&buffer, "\nSR: %s vs %s", srs[0], srs[1])
+	fmt.Fprintf(&buffer, "\nwin rate: %d%% vs %d%%", winRate(blobs[0].GetCompWDL()), winRate(blobs[1].GetCompWDL()))
+	fmt.Fprintf(&buffer, "\nKPD: %.2f vs %.2f", blobs[0].GetCompKPD(), blobs[1].GetCompKPD())
+	fmt.Fprintf(&buffer, "\ntop heroes: %s vs %s", formatTopHeroes(blobs[0]), formatTopHeroes(blobs[1]))
+	if overlap := getHeroOverlap(blobs[0], blobs[1]); len(overlap) > 0 {
+		buffer.WriteString("\nboth play:")
+		for i, heroId := range overlap {
+			if i > 0 {
+				buffer.WriteString(",")
+			}
+			hero, _ := overwatch.GetHero(heroId)
+			fmt.Fprintf(&buffer, " %s (%d%% vs %d%% won)", hero.Name, heroWinRate(blobs[0], heroId), heroWinRate(blobs[1], heroId))
+		}
+	}
+
+	sessions, wdls, err := bot.getSessionsTogether(userIds[0], userIds[1])
+	if err != nil {
+		bot.logger.WithError(err).Error("could not get sessions")
+	} else if sessions > 0 {
+		fmt.Fprintf(&buffer, "\nplayed together in %d %s: %d W / %d D / %d L vs %d W / %d D / %d L",
+			sessions, plural(sessions, "session", "sessions"),
+			wdls[0].Win, wdls[0].Draw, wdls[0].Loss, wdls[1].Win, wdls[1].Draw, wdls[1].Loss)
+	}
+
+	bot.reply(message, buffer.String())
+}
+
+func formatCompareSR(sr int) string {
+	if tier, ok := overwatch.GetTier(sr); ok {
+		return fmt.Sprintf("%d (%s)", sr, tier.Name)
+	}
+	return "unranked"
+}
+
+func formatTopHeroes(regionBlob *overwatch.RegionBlob) string {
+	heroIds := regionBlob.GetAllHeroStats().HeroesByGamesPlayed()
+	if len(heroIds) == 0 {
+		return "none"
+	}
+	if len(heroIds) > compareHeroes {
+		heroIds = heroIds[:compareHeroes]
+	}
+
+	var heroNames []string
+	for _, heroId := range heroIds {
+		hero, _ := overwatch.GetHero(heroId)
+		heroNames = append(heroNames, hero.Name)
+	}
+	return strings.Join(heroNames, ", ")
+}
+
+// Returns the most played of the heroes both players played in comp this
+// season, in the first player's order
+func getHeroOverlap(regionBlob1 *overwatch.RegionBlob, regionBlob2 *overwatch.RegionBlob) []string {
+	var overlap []string
+	for _, heroId := range regionBlob1.GetAllHeroStats().HeroesByGamesPlayed() {
+		if heroStats := regionBlob2.GetAllHeroStats().HeroStats(heroId); heroStats != nil && heroStats.GeneralStats.GamesPlayed > 0 {
+			overlap = append(overlap, heroId)
+		}
+		if len(overlap) == compareHeroes {
+			break
+		}
+	}
+
+	return overlap
+}
+
+func heroWinRate(regionBlob *overwatch.RegionBlob, heroId string) int {
+	heroStats := regionBlob.GetAllHeroStats().HeroStats(heroId)
+	if heroStats == nil {
+		return 0
+	}
+	return winRate(overwatch.WDL{Win: int(heroStats.GeneralStats.GamesWon), Loss: int(heroStats.GeneralStats.GamesLost)})
+}
+
+// Returns the number of the first user's sessions that overlapped with
+// sessions of the second user, and the comp results of each user in their
+// overlapping sessions. Sessions only overlapping in time does not mean
+// every game was played in the same group.
+func (bot *Bot) getSessionsTogether(userId1 string, userId2 string) (int, [2]overwatch.WDL, error) {
+	var wdls [2]overwatch.WDL
+
+	records1, err := bot.store.GetSessions(userId1, time.Time{})
+	if err != nil {
+		return 0, wdls, err
+	}
+	records2, err := bot.store.GetSessions(userId2, time.Time{})
+	if err != nil {
+		return 0, wdls, err
+	}
+
+	sessions := 0
+	for i, records := range [][]store.SessionRecord{records1, records2} {
+		others := records2
+		if i == 1 {
+			others = records1
+		}
+
+		for _, record := range records {
+			if !overlapsAny(record, others) {
+				continue
+			}
+			if i == 0 {
+				sessions++
+			}
+			compWDL := record.CompWDL()
+			wdls[i].Win += compWDL.Win
+			wdls[i].Draw += compWDL.Draw
+			wdls[i].Loss += compWDL.Loss
+		}
+	}
+
+	return sessions, wdls, nil
+}
+
+func overlapsAny(record store.SessionRecord, others []store.SessionRecord) bool {
+	for _, other := range others {
+		if record.Start.Before(other.End) && other.Start.Before(record.End) {
+			return true
+		}
+	}
+	return false
+}
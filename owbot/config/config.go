@@ -0,0 +1,547 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+)
+
+// Prefix of all environment variables that override config file values
+const envPrefix = "OVERSESSIONS_"
+
+// Config holds all settings of the bot. Values are resolved in the order
+// defaults, config file, environment variables and finally command line flags.
+type Config struct {
+	Token         string `yaml:"token"`
+	BattleTagFile string `yaml:"battleTagFile"`
+	DBFile        string `yaml:"dbFile"`
+
+	Overwatch  OverwatchConfig  `yaml:"overwatch"`
+	Polling    PollingConfig    `yaml:"polling"`
+	Log        LogConfig        `yaml:"log"`
+	Guild      GuildConfig      `yaml:"guild"`
+	HTTP       HTTPConfig       `yaml:"http"`
+	Digest     DigestConfig     `yaml:"digest"`
+	Milestones MilestonesConfig `yaml:"milestones"`
+
+	// Start dates of comp seasons. Seasons not listed are detected from
+	// the comp stats resetting
+	Seasons []SeasonConfig `yaml:"seasons"`
+}
+
+type OverwatchConfig struct {
+	// The base url of the stats provider (owapi)
+	BaseUrl string `yaml:"baseUrl"`
+}
+
+type PollingConfig struct {
+	// Number of times stats are fetched after a session ends, waiting
+	// RetryInterval in between, until a change is visible
+	MaxGetUserStatsAttempts int           `yaml:"maxGetUserStatsAttempts"`
+	RetryInterval           time.Duration `yaml:"retryInterval"`
+
+	// Duration within which a presence change is considered recent
+	RecentDuration time.Duration `yaml:"recentDuration"`
+
+	// Longest amount of time a command is processed until given up on
+	CommandTimeout     time.Duration `yaml:"commandTimeout"`
+	LongCommandTimeout time.Duration `yaml:"longCommandTimeout"`
+
+	// How often the config and battleTag files are checked for changes.
+	// Zero disables watching, leaving SIGHUP as the only way to reload
+	FileWatchInterval time.Duration `yaml:"fileWatchInterval"`
+
+	// How often the stats of players in a session are fetched, to tell the
+	// session's matches apart. Zero only fetches them at the start and end
+	SessionInterval time.Duration `yaml:"sessionInterval"`
+}
+
+type LogConfig struct {
+	Level string `yaml:"level"`
+	// "text" or "json"
+	Format string `yaml:"format"`
+	// "file" or "stdout"
+	Output string `yaml:"output"`
+
+	// Log file used when the output is "file"
+	File string `yaml:"file"`
+	// The log file is rotated when it reaches MaxSizeMB, and rotated files
+	// are removed when older than MaxAgeDays or when there are more than
+	// MaxBackups of them. Zero keeps rotated files forever
+	MaxSizeMB  int  `yaml:"maxSizeMB"`
+	MaxAgeDays int  `yaml:"maxAgeDays"`
+	MaxBackups int  `yaml:"maxBackups"`
+	Compress   bool `yaml:"compress"`
+}
+
+// GuildConfig holds the defaults used for every guild the bot is in.
+type GuildConfig struct {
+	// Text channels matching this pattern are used for reports and commands
+	ChannelPattern string `yaml:"channelPattern"`
+
+	// Files replacing the built-in report templates, by template name
+	// ("session" or "nochange"). Guild admins can still override these.
+	TemplateFiles map[string]string `yaml:"templateFiles"`
+
+	// Attach an image of the player's recent SR to session reports
+	ReportSparkline bool `yaml:"reportSparkline"`
+
+	// Members with one of these roles, by role name, can run admin
+	// commands, as can members with the Manage Server permission.
+	// Moderators can link and unlink other users.
+	AdminRoles     []string `yaml:"adminRoles"`
+	ModeratorRoles []string `yaml:"moderatorRoles"`
+
+	// Users linking themselves must prove they own the battleTag by playing
+	// a game on a hero picked by the bot within VerificationExpiry.
+	// Moderators linking other users are not asked to.
+	VerifyLinks        bool          `yaml:"verifyLinks"`
+	VerificationExpiry time.Duration `yaml:"verificationExpiry"`
+}
+
+type HTTPConfig struct {
+	// Address serving /metrics and /healthz, eg. ":8080". Empty disables the listener
+	Listen string `yaml:"listen"`
+	// Token required by the admin api under /api/. Empty disables the api
+	AdminToken string `yaml:"adminToken"`
+	// Serve the session history dashboard under /dashboard/
+	Dashboard bool `yaml:"dashboard"`
+}
+
+// MilestonesConfig sets when announcements are posted. Zero disables the
+// announcement
+type MilestonesConfig struct {
+	// Comp games won or lost in a row
+	StreakLength int `yaml:"streakLength"`
+	// Every this many levels, besides every prestige
+	LevelInterval int `yaml:"levelInterval"`
+	// Every this many comp games played in a season
+	GamesInterval int `yaml:"gamesInterval"`
+}
+
+type DigestConfig struct {
+	// Post a digest of the sessions of the past day every day, and of the
+	// past week once a week
+	Daily  bool `yaml:"daily"`
+	Weekly bool `yaml:"weekly"`
+
+	// Time of day the digests are posted, eg. "20:00"
+	Time string `yaml:"time"`
+	// Day the weekly digest is posted, eg. "sunday"
+	Weekday string `yaml:"weekday"`
+	// Time zone of Time and Weekday, eg. "Europe/Berlin". Empty uses the
+	// time zone of the host
+	Timezone string `yaml:"timezone"`
+}
+
+// Returns the hour and minute of Time
+func (digest DigestConfig) Clock() (int, int, error) {
+	t, err := time.Parse("15:04", digest.Time)
+	if err != nil {
+		return 0, 0, err
+	}
+	return t.Hour(), t.Minute(), nil
+}
+
+func (digest DigestConfig) ParseWeekday() (time.Weekday, error) {
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		if strings.EqualFold(digest.Weekday, weekday.String()) {
+			return weekday, nil
+		}
+	}
+	return time.Sunday, fmt.Errorf("unknown weekday %q", digest.Weekday)
+}
+
+func (digest DigestConfig) Location() (*time.Location, error) {
+	if digest.Timezone == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(digest.Timezone)
+}
+
+type SeasonConfig struct {
+	Number int `yaml:"number"`
+	// Local date the season starts, eg. "2017-11-01"
+	Start string `yaml:"start"`
+}
+
+func (season SeasonConfig) StartTime() (time.Time, error) {
+	return time.ParseInLocation("2006-01-02", season.Start, time.Local)
+}
+
+// Returns the number of the configured season that includes t, if any
+func (config *Config) SeasonAt(t time.Time) (int, bool) {
+	number, found := 0, false
+	var latest time.Time
+	for _, season := range config.Seasons {
+		start, err := season.StartTime()
+		if err != nil || start.After(t) || (found && start.Before(latest)) {
+			continue
+		}
+		number, found, latest = season.Number, true, start
+	}
+	return number, found
+}
+
+// Redacted returns a copy of the config without the secrets, for showing
+// it in Discord
+func (config *Config) Redacted() *Config {
+	redacted := *config
+	if redacted.Token != "" {
+		redacted.Token = "<redacted>"
+	}
+	if redacted.HTTP.AdminToken != "" {
+		redacted.HTTP.AdminToken = "<redacted>"
+	}
+	return &redacted
+}
+
+// Default returns a Config with every optional setting filled in.
+func Default() *Config {
+	return &Config{
+		DBFile: "oversessions.db",
+		Overwatch: OverwatchConfig{
+			BaseUrl: "https://owapi.net/api/v3/",
+		},
+		Polling: PollingConfig{
+			MaxGetUserStatsAttempts: 10,
+			RetryInterval:           1 * time.Minute,
+			RecentDuration:          2 * time.Second,
+			CommandTimeout:          10 * time.Second,
+			LongCommandTimeout:      30 * time.Second,
+			FileWatchInterval:       10 * time.Second,
+			SessionInterval:         5 * time.Minute,
+		},
+		Log: LogConfig{
+			Level:      "info",
+			Format:     "text",
+			Output:     "file",
+			File:       "oversessions.log",
+			MaxSizeMB:  100,
+			MaxAgeDays: 30,
+			MaxBackups: 10,
+		},
+		Guild: GuildConfig{
+			ChannelPattern:     `^over.*$`,
+			VerificationExpiry: 24 * time.Hour,
+		},
+		Digest: DigestConfig{
+			Time:    "20:00",
+			Weekday: "sunday",
+		},
+		Milestones: MilestonesConfig{
+			StreakLength:  5,
+			LevelInterval: 25,
+			GamesInterval: 50,
+		},
+	}
+}
+
+// Load returns the default config overlaid with the YAML file at path (if
+// path is not empty) and then with any OVERSESSIONS_* environment variables.
+func Load(path string) (*Config, error) {
+	config := Default()
+
+	if path != "" {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("could not read config file %s: %v", path, err)
+		}
+		if err := yaml.UnmarshalStrict(data, config); err != nil {
+			return nil, fmt.Errorf("could not parse config file %s: %v", path, err)
+		}
+	}
+
+	if err := config.applyEnv(os.LookupEnv); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// ApplyFlags overrides the config with the flags of flagSet that were given
+// on the command line, so that flags take precedence over the config file
+// and environment variables. The flags are token, battleTags, dbfile and
+// debug.
+func (config *Config) ApplyFlags(flagSet *flag.FlagSet) {
+	flagSet.Visit(func(f *flag.Flag) {
+		value := f.Value.String()
+		switch f.Name {
+		case "token":
+			config.Token = value
+		case "battleTags":
+			config.BattleTagFile = value
+		case "dbfile":
+			config.DBFile = value
+		case "debug":
+			if value == "true" {
+				config.Log.Level = "debug"
+			}
+		}
+	})
+}
+
+type envOverride struct {
+	name string
+	set  func(config *Config, value string) error
+}
+
+var envOverrides = []envOverride{
+	{"TOKEN", func(config *Config, value string) error { config.Token = value; return nil }},
+	{"BATTLETAGS", func(config *Config, value string) error { config.BattleTagFile = value; return nil }},
+	{"DBFILE", func(config *Config, value string) error { config.DBFile = value; return nil }},
+	{"OVERWATCH_BASE_URL", func(config *Config, value string) error { config.Overwatch.BaseUrl = value; return nil }},
+	{"MAX_GET_USER_STATS_ATTEMPTS", func(config *Config, value string) error {
+		return setInt(&config.Polling.MaxGetUserStatsAttempts, value)
+	}},
+	{"RETRY_INTERVAL", func(config *Config, value string) error {
+		return setDuration(&config.Polling.RetryInterval, value)
+	}},
+	{"RECENT_DURATION", func(config *Config, value string) error {
+		return setDuration(&config.Polling.RecentDuration, value)
+	}},
+	{"COMMAND_TIMEOUT", func(config *Config, value string) error {
+		return setDuration(&config.Polling.CommandTimeout, value)
+	}},
+	{"LONG_COMMAND_TIMEOUT", func(config *Config, value string) error {
+		return setDuration(&config.Polling.LongCommandTimeout, value)
+	}},
+	{"FILE_WATCH_INTERVAL", func(config *Config, value string) error {
+		return setDuration(&config.Polling.FileWatchInterval, value)
+	}},
+	{"SESSION_INTERVAL", func(config *Config, value string) error {
+		return setDuration(&config.Polling.SessionInterval, value)
+	}},
+	{"LOG_LEVEL", func(config *Config, value string) error { config.Log.Level = value; return nil }},
+	{"LOG_FORMAT", func(config *Config, value string) error { config.Log.Format = value; return nil }},
+	{"LOG_OUTPUT", func(config *Config, value string) error { config.Log.Output = value; return nil }},
+	{"LOG_FILE", func(config *Config, value string) error { config.Log.File = value; return nil }},
+	{"LOG_MAX_SIZE_MB", func(config *Config, value string) error { return setInt(&config.Log.MaxSizeMB, value) }},
+	{"LOG_MAX_AGE_DAYS", func(config *Config, value string) error { return setInt(&config.Log.MaxAgeDays, value) }},
+	{"LOG_MAX_BACKUPS", func(config *Config, value string) error { return setInt(&config.Log.MaxBackups, value) }},
+	{"LOG_COMPRESS", func(config *Config, value string) error { return setBool(&config.Log.Compress, value) }},
+	{"CHANNEL_PATTERN", func(config *Config, value string) error { config.Guild.ChannelPattern = value; return nil }},
+	{"ADMIN_ROLES", func(config *Config, value string) error { return setList(&config.Guild.AdminRoles, value) }},
+	{"MODERATOR_ROLES", func(config *Config, value string) error { return setList(&config.Guild.ModeratorRoles, value) }},
+	{"VERIFY_LINKS", func(config *Config, value string) error { return setBool(&config.Guild.VerifyLinks, value) }},
+	{"VERIFICATION_EXPIRY", func(config *Config, value string) error {
+		return setDuration(&config.Guild.VerificationExpiry, value)
+	}},
+	{"REPORT_SPARKLINE", func(config *Config, value string) error { return setBool(&config.Guild.ReportSparkline, value) }},
+	{"HTTP_LISTEN", func(config *Config, value string) error { config.HTTP.Listen = value; return nil }},
+	{"HTTP_ADMIN_TOKEN", func(config *Config, value string) error { config.HTTP.AdminToken = value; return nil }},
+	{"HTTP_DASHBOARD", func(config *Config, value string) error { return setBool(&config.HTTP.Dashboard, value) }},
+	{"DIGEST_DAILY", func(config *Config, value string) error { return setBool(&config.Digest.Daily, value) }},
+	{"DIGEST_WEEKLY", func(config *Config, value string) error { return setBool(&config.Digest.Weekly, value) }},
+	{"DIGEST_TIME", func(config *Config, value string) error { config.Digest.Time = value; return nil }},
+	{"DIGEST_WEEKDAY", func(config *Config, value string) error { config.Digest.Weekday = value; return nil }},
+	{"DIGEST_TIMEZONE", func(config *Config, value string) error { config.Digest.Timezone = value; return nil }},
+	{"MILESTONE_STREAK_LENGTH", func(config *Config, value string) error { return setInt(&config.Milestones.StreakLength, value) }},
+	{"MILESTONE_LEVEL_INTERVAL", func(config *Config, value string) error { return setInt(&config.Milestones.LevelInterval, value) }},
+	{"MILESTONE_GAMES_INTERVAL", func(config *Config, value string) error { return setInt(&config.Milestones.GamesInterval, value) }},
+}
+
+func (config *Config) applyEnv(lookupEnv func(string) (string, bool)) error {
+	for _, override := range envOverrides {
+		name := envPrefix + override.name
+		value, ok := lookupEnv(name)
+		if !ok {
+			continue
+		}
+		if err := override.set(config, value); err != nil {
+			return fmt.Errorf("invalid value for %s: %v", name, err)
+		}
+	}
+
+	return nil
+}
+
+func setInt(field *int, value string) error {
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return err
+	}
+	*field = i
+	return nil
+}
+
+func setBool(field *bool, value string) error {
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return err
+	}
+	*field = b
+	return nil
+}
+
+// Sets a comma separated list, eg. "admins, mods"
+func setList(field *[]string, value string) error {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	*field = list
+	return nil
+}
+
+func setDuration(field *time.Duration, value string) error {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*field = d
+	return nil
+}
+
+// Validate checks that the config is complete and consistent, returning an
+// error describing every problem found.
+func (config *Config) Validate() error {
+	var problems []string
+
+	if config.Token == "" {
+		problems = append(problems, "token is required (set it in the config file, OVERSESSIONS_TOKEN or -token)")
+	}
+
+	if config.DBFile == "" {
+		problems = append(problems, "dbFile is required")
+	}
+
+	if config.BattleTagFile != "" {
+		if _, err := os.Stat(config.BattleTagFile); err != nil {
+			problems = append(problems, fmt.Sprintf("battleTagFile: %v", err))
+		}
+	}
+
+	if baseUrl, err := url.Parse(config.Overwatch.BaseUrl); err != nil {
+		problems = append(problems, fmt.Sprintf("overwatch.baseUrl: %v", err))
+	} else if !baseUrl.IsAbs() {
+		problems = append(problems, "overwatch.baseUrl must be an absolute url")
+	} else if !strings.HasSuffix(baseUrl.Path, "/") {
+		problems = append(problems, "overwatch.baseUrl must end with a slash")
+	}
+
+	if config.Polling.MaxGetUserStatsAttempts < 1 {
+		problems = append(problems, "polling.maxGetUserStatsAttempts must be at least 1")
+	}
+	if config.Polling.RetryInterval <= 0 {
+		problems = append(problems, "polling.retryInterval must be positive")
+	}
+	if config.Polling.RecentDuration < 0 {
+		problems = append(problems, "polling.recentDuration must not be negative")
+	}
+	if config.Polling.CommandTimeout <= 0 {
+		problems = append(problems, "polling.commandTimeout must be positive")
+	}
+	if config.Polling.LongCommandTimeout <= 0 {
+		problems = append(problems, "polling.longCommandTimeout must be positive")
+	}
+	if config.Polling.FileWatchInterval < 0 {
+		problems = append(problems, "polling.fileWatchInterval must not be negative")
+	}
+	if config.Polling.SessionInterval < 0 {
+		problems = append(problems, "polling.sessionInterval must not be negative")
+	}
+
+	if _, err := logrus.ParseLevel(config.Log.Level); err != nil {
+		problems = append(problems, fmt.Sprintf("log.level: %v", err))
+	}
+	if config.Log.Format != "text" && config.Log.Format != "json" {
+		problems = append(problems, "log.format must be text or json")
+	}
+	if config.Log.Output != "file" && config.Log.Output != "stdout" {
+		problems = append(problems, "log.output must be file or stdout")
+	}
+	if config.Log.Output == "file" && config.Log.File == "" {
+		problems = append(problems, "log.file is required when logging to a file")
+	}
+	if config.Log.MaxSizeMB < 1 {
+		problems = append(problems, "log.maxSizeMB must be at least 1")
+	}
+	if config.Log.MaxAgeDays < 0 || config.Log.MaxBackups < 0 {
+		problems = append(problems, "log.maxAgeDays and log.maxBackups must not be negative")
+	}
+
+	if _, err := regexp.Compile(config.Guild.ChannelPattern); err != nil {
+		problems = append(problems, fmt.Sprintf("guild.channelPattern: %v", err))
+	}
+	if hasEmptyName(config.Guild.AdminRoles) {
+		problems = append(problems, "guild.adminRoles must not contain empty role names")
+	}
+	if hasEmptyName(config.Guild.ModeratorRoles) {
+		problems = append(problems, "guild.moderatorRoles must not contain empty role names")
+	}
+	if config.Guild.VerificationExpiry <= 0 {
+		problems = append(problems, "guild.verificationExpiry must be positive")
+	}
+	for name, file := range config.Guild.TemplateFiles {
+		if _, err := os.Stat(file); err != nil {
+			problems = append(problems, fmt.Sprintf("guild.templateFiles.%s: %v", name, err))
+		}
+	}
+
+	if config.HTTP.Listen != "" {
+		if _, _, err := net.SplitHostPort(config.HTTP.Listen); err != nil {
+			problems = append(problems, fmt.Sprintf("http.listen: %v", err))
+		}
+	}
+	if config.HTTP.AdminToken != "" && config.HTTP.Listen == "" {
+		problems = append(problems, "http.adminToken requires http.listen")
+	}
+	if config.HTTP.Dashboard && config.HTTP.Listen == "" {
+		problems = append(problems, "http.dashboard requires http.listen")
+	}
+	if config.HTTP.AdminToken != "" && len(config.HTTP.AdminToken) < 16 {
+		problems = append(problems, "http.adminToken must be at least 16 characters")
+	}
+
+	if _, _, err := config.Digest.Clock(); err != nil {
+		problems = append(problems, fmt.Sprintf("digest.time must be written like 20:00, not %q", config.Digest.Time))
+	}
+	if _, err := config.Digest.ParseWeekday(); err != nil {
+		problems = append(problems, fmt.Sprintf("digest.weekday: %v", err))
+	}
+	if _, err := config.Digest.Location(); err != nil {
+		problems = append(problems, fmt.Sprintf("digest.timezone: %v", err))
+	}
+
+	if config.Milestones.StreakLength < 0 || config.Milestones.LevelInterval < 0 || config.Milestones.GamesInterval < 0 {
+		problems = append(problems, "milestones settings must not be negative")
+	}
+
+	for i, season := range config.Seasons {
+		if season.Number < 1 {
+			problems = append(problems, fmt.Sprintf("seasons[%d].number must be at least 1", i))
+		}
+		if _, err := season.StartTime(); err != nil {
+			problems = append(problems, fmt.Sprintf("seasons[%d].start must be written like 2017-11-01, not %q", i, season.Start))
+		}
+	}
+
+	if len(problems) > 0 {
+		return errors.New("invalid config: " + strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+func hasEmptyName(names []string) bool {
+	for _, name := range names {
+		if strings.TrimSpace(name) == "" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,276 @@
+package config
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Writes the YAML to a config file in a temporary directory, returning its
+// path and a func removing it
+func writeConfigFile(t *testing.T, yaml string) (string, func()) {
+	dir, err := ioutil.TempDir("", "oversessions-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "config.yaml")
+	if err := ioutil.WriteFile(path, []byte(yaml), 0644); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return path, func() { os.RemoveAll(dir) }
+}
+
+// Returns a lookupEnv func serving the variables
+func envLookup(env map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+}
+
+func TestLoadYAMLOverDefaults(t *testing.T) {
+	path, remove := writeConfigFile(t, `
+token: secret
+dbFile: bot.db
+polling:
+  retryInterval: 30s
+guild:
+  adminRoles: [Officers]
+  verifyLinks: true
+`)
+	defer remove()
+
+	config, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Default()
+	want.Token = "secret"
+	want.DBFile = "bot.db"
+	want.Polling.RetryInterval = 30 * time.Second
+	want.Guild.AdminRoles = []string{"Officers"}
+	want.Guild.VerifyLinks = true
+	if !reflect.DeepEqual(config, want) {
+		t.Errorf("Load = %+v, want %+v", config, want)
+	}
+}
+
+func TestLoadWithoutFile(t *testing.T) {
+	config, err := Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(config, Default()) {
+		t.Errorf("Load without a file = %+v, want the defaults", config)
+	}
+}
+
+func TestLoadRejectsUnknownKeys(t *testing.T) {
+	path, remove := writeConfigFile(t, "polling:\n  retryIntervall: 30s\n")
+	defer remove()
+
+	if _, err := Load(path); err == nil {
+		t.Error("Load accepted a misspelled key")
+	}
+}
+
+func TestExampleConfigIsValid(t *testing.T) {
+	config, err := Load(filepath.Join("..", "..", "oversessions.example.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Token = "secret"
+	config.BattleTagFile = ""
+	if err := config.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestEnvOverrides(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		get   func(config *Config) interface{}
+		want  interface{}
+	}{
+		{"TOKEN", "secret", func(c *Config) interface{} { return c.Token }, "secret"},
+		{"BATTLETAGS", "tags", func(c *Config) interface{} { return c.BattleTagFile }, "tags"},
+		{"DBFILE", "bot.db", func(c *Config) interface{} { return c.DBFile }, "bot.db"},
+		{"OVERWATCH_BASE_URL", "http://owapi/", func(c *Config) interface{} { return c.Overwatch.BaseUrl }, "http://owapi/"},
+		{"MAX_GET_USER_STATS_ATTEMPTS", "3", func(c *Config) interface{} { return c.Polling.MaxGetUserStatsAttempts }, 3},
+		{"RETRY_INTERVAL", "30s", func(c *Config) interface{} { return c.Polling.RetryInterval }, 30 * time.Second},
+		{"RECENT_DURATION", "5s", func(c *Config) interface{} { return c.Polling.RecentDuration }, 5 * time.Second},
+		{"COMMAND_TIMEOUT", "3s", func(c *Config) interface{} { return c.Polling.CommandTimeout }, 3 * time.Second},
+		{"LONG_COMMAND_TIMEOUT", "1m", func(c *Config) interface{} { return c.Polling.LongCommandTimeout }, time.Minute},
+		{"FILE_WATCH_INTERVAL", "0s", func(c *Config) interface{} { return c.Polling.FileWatchInterval }, time.Duration(0)},
+		{"SESSION_INTERVAL", "2m", func(c *Config) interface{} { return c.Polling.SessionInterval }, 2 * time.Minute},
+		{"LOG_LEVEL", "debug", func(c *Config) interface{} { return c.Log.Level }, "debug"},
+		{"LOG_FORMAT", "json", func(c *Config) interface{} { return c.Log.Format }, "json"},
+		{"LOG_OUTPUT", "stdout", func(c *Config) interface{} { return c.Log.Output }, "stdout"},
+		{"LOG_FILE", "bot.log", func(c *Config) interface{} { return c.Log.File }, "bot.log"},
+		{"LOG_MAX_SIZE_MB", "5", func(c *Config) interface{} { return c.Log.MaxSizeMB }, 5},
+		{"LOG_MAX_AGE_DAYS", "7", func(c *Config) interface{} { return c.Log.MaxAgeDays }, 7},
+		{"LOG_MAX_BACKUPS", "2", func(c *Config) interface{} { return c.Log.MaxBackups }, 2},
+		{"LOG_COMPRESS", "true", func(c *Config) interface{} { return c.Log.Compress }, true},
+		{"CHANNEL_PATTERN", "^ow$", func(c *Config) interface{} { return c.Guild.ChannelPattern }, "^ow$"},
+		{"ADMIN_ROLES", "Officers, Admins,", func(c *Config) interface{} { return c.Guild.AdminRoles }, []string{"Officers", "Admins"}},
+		{"MODERATOR_ROLES", "Mods", func(c *Config) interface{} { return c.Guild.ModeratorRoles }, []string{"Mods"}},
+		{"VERIFY_LINKS", "true", func(c *Config) interface{} { return c.Guild.VerifyLinks }, true},
+		{"VERIFICATION_EXPIRY", "2h", func(c *Config) interface{} { return c.Guild.VerificationExpiry }, 2 * time.Hour},
+		{"REPORT_SPARKLINE", "true", func(c *Config) interface{} { return c.Guild.ReportSparkline }, true},
+		{"HTTP_LISTEN", ":8080", func(c *Config) interface{} { return c.HTTP.Listen }, ":8080"},
+		{"HTTP_ADMIN_TOKEN", "0123456789abcdef", func(c *Config) interface{} { return c.HTTP.AdminToken }, "0123456789abcdef"},
+		{"HTTP_DASHBOARD", "true", func(c *Config) interface{} { return c.HTTP.Dashboard }, true},
+		{"DIGEST_DAILY", "true", func(c *Config) interface{} { return c.Digest.Daily }, true},
+		{"DIGEST_WEEKLY", "true", func(c *Config) interface{} { return c.Digest.Weekly }, true},
+		{"DIGEST_TIME", "18:30", func(c *Config) interface{} { return c.Digest.Time }, "18:30"},
+		{"DIGEST_WEEKDAY", "friday", func(c *Config) interface{} { return c.Digest.Weekday }, "friday"},
+		{"DIGEST_TIMEZONE", "UTC", func(c *Config) interface{} { return c.Digest.Timezone }, "UTC"},
+		{"MILESTONE_STREAK_LENGTH", "3", func(c *Config) interface{} { return c.Milestones.StreakLength }, 3},
+		{"MILESTONE_LEVEL_INTERVAL", "10", func(c *Config) interface{} { return c.Milestones.LevelInterval }, 10},
+		{"MILESTONE_GAMES_INTERVAL", "20", func(c *Config) interface{} { return c.Milestones.GamesInterval }, 20},
+	}
+
+	// every override is covered
+	tested := make(map[string]bool)
+	for _, test := range tests {
+		tested[test.name] = true
+	}
+	for _, override := range envOverrides {
+		if !tested[override.name] {
+			t.Errorf("no test for %s%s", envPrefix, override.name)
+		}
+	}
+
+	for _, test := range tests {
+		config := Default()
+		if err := config.applyEnv(envLookup(map[string]string{envPrefix + test.name: test.value})); err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if got := test.get(config); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s=%q set %#v, want %#v", test.name, test.value, got, test.want)
+		}
+	}
+}
+
+func TestEnvOverrideErrors(t *testing.T) {
+	for _, name := range []string{"RETRY_INTERVAL", "LOG_MAX_SIZE_MB", "VERIFY_LINKS"} {
+		config := Default()
+		err := config.applyEnv(envLookup(map[string]string{envPrefix + name: "lots"}))
+		if err == nil || !strings.Contains(err.Error(), envPrefix+name) {
+			t.Errorf("%s=lots: got error %v, want one naming the variable", name, err)
+		}
+	}
+}
+
+func TestPrecedence(t *testing.T) {
+	path, remove := writeConfigFile(t, "token: file\ndbFile: file.db\nbattleTagFile: file.tags\n")
+	defer remove()
+
+	config, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := map[string]string{envPrefix + "TOKEN": "env", envPrefix + "DBFILE": "env.db"}
+	if err := config.applyEnv(envLookup(env)); err != nil {
+		t.Fatal(err)
+	}
+
+	flagSet := flag.NewFlagSet("oversessions", flag.ContinueOnError)
+	flagSet.String("token", "", "")
+	flagSet.String("battleTags", "", "")
+	flagSet.String("dbfile", "default.db", "")
+	flagSet.Bool("debug", false, "")
+	if err := flagSet.Parse([]string{"-token", "flag", "-debug"}); err != nil {
+		t.Fatal(err)
+	}
+	config.ApplyFlags(flagSet)
+
+	// flags beat the environment, which beats the file, and flags that
+	// were not given keep their default out of the way
+	if config.Token != "flag" {
+		t.Errorf("token = %q, want the flag", config.Token)
+	}
+	if config.DBFile != "env.db" {
+		t.Errorf("dbFile = %q, want the environment variable", config.DBFile)
+	}
+	if config.BattleTagFile != "file.tags" {
+		t.Errorf("battleTagFile = %q, want the config file value", config.BattleTagFile)
+	}
+	if config.Log.Level != "debug" {
+		t.Errorf("log.level = %q, want debug from -debug", config.Log.Level)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := func() *Config {
+		config := Default()
+		config.Token = "secret"
+		return config
+	}
+
+	tests := []struct {
+		name    string
+		change  func(config *Config)
+		problem string
+	}{
+		{"defaults with a token", func(config *Config) {}, ""},
+		{"missing token", func(config *Config) { config.Token = "" }, "token is required"},
+		{"missing dbFile", func(config *Config) { config.DBFile = "" }, "dbFile is required"},
+		{"zero retry interval", func(config *Config) { config.Polling.RetryInterval = 0 }, "polling.retryInterval must be positive"},
+		{"negative session interval", func(config *Config) { config.Polling.SessionInterval = -time.Second }, "polling.sessionInterval must not be negative"},
+		{"zero verification expiry", func(config *Config) { config.Guild.VerificationExpiry = 0 }, "guild.verificationExpiry must be positive"},
+		{"log file missing", func(config *Config) { config.Log.File = "" }, "log.file is required"},
+		{"bad digest time", func(config *Config) { config.Digest.Time = "8pm" }, "digest.time"},
+		{"base url without slash", func(config *Config) { config.Overwatch.BaseUrl = "https://owapi.net/api/v3" }, "must end with a slash"},
+		{"dashboard without listen", func(config *Config) { config.HTTP.Dashboard = true }, "http.dashboard requires http.listen"},
+	}
+
+	for _, test := range tests {
+		config := valid()
+		test.change(config)
+		err := config.Validate()
+		if test.problem == "" {
+			if err != nil {
+				t.Errorf("%s: %v", test.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), test.problem) {
+			t.Errorf("%s: got error %v, want %q", test.name, err, test.problem)
+		}
+	}
+}
+
+func TestValidateCollectsEveryProblem(t *testing.T) {
+	config := Default()
+	config.DBFile = ""
+	config.Polling.RetryInterval = 0
+
+	err := config.Validate()
+	if err == nil {
+		t.Fatal("Validate accepted an invalid config")
+	}
+	for _, problem := range []string{"token is required", "dbFile is required", "polling.retryInterval"} {
+		if !strings.Contains(err.Error(), problem) {
+			t.Errorf("error %q does not mention %q", err, problem)
+		}
+	}
+}
+
+func TestBadDurationInFile(t *testing.T) {
+	path, remove := writeConfigFile(t, "polling:\n  retryInterval: soon\n")
+	defer remove()
+
+	if _, err := Load(path); err == nil {
+		t.Error("Load accepted an invalid duration")
+	}
+}
@@ -0,0 +1,314 @@
+package owbot
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/snakelayer/discord-oversessions/owbot/overwatch"
+	"github.com/snakelayer/discord-oversessions/owbot/store"
+)
+
+// Number of days of sessions shown unless the days query parameter is given
+const defaultDashboardDays = 90
+
+// A row of the guild leaderboard
+type leaderboardRow struct {
+	UserId    string
+	Name      string
+	BattleTag string
+	SR        int
+	Sessions  int
+	Hours     float64
+	CompWDL   overwatch.WDL
+}
+
+func (row leaderboardRow) WinRate() int {
+	return winRate(row.CompWDL)
+}
+
+// A row of a player's hero breakdown
+type heroRow struct {
+	Name string
+	WDL  overwatch.WDL
+}
+
+func (row heroRow) WinRate() int {
+	return winRate(row.WDL)
+}
+
+func (row heroRow) Games() int {
+	return row.WDL.Win + row.WDL.Draw + row.WDL.Loss
+}
+
+// Percentage of comp games won, not counting draws
+func winRate(wdl overwatch.WDL) int {
+	if wdl.Win+wdl.Loss == 0 {
+		return 0
+	}
+	return wdl.Win * 100 / (wdl.Win + wdl.Loss)
+}
+
+type leaderboardPage struct {
+	Days int
+	Rows []leaderboardRow
+}
+
+type playerPage struct {
+	Days      int
+	Name      string
+	BattleTag string
+	SR        int
+	SRHidden  bool
+	Chart     template.HTML
+	Sessions  []store.SessionRecord
+	Heroes    []heroRow
+}
+
+// Serves the dashboard under /dashboard/
+func (bot *Bot) registerDashboardHandlers(mux *http.ServeMux) {
+	mux.HandleFunc("/dashboard/", bot.dashboardHandler)
+}
+
+func (bot *Bot) dashboardHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	days := defaultDashboardDays
+	if daysParam := r.URL.Query().Get("days"); daysParam != "" {
+		var err error
+		if days, err = strconv.Atoi(daysParam); err != nil || days < 1 {
+			http.Error(w, "invalid days", http.StatusBadRequest)
+			return
+		}
+	}
+	since := time.Now().AddDate(0, 0, -days)
+
+	path := strings.TrimPrefix(r.URL.Path, "/dashboard/")
+	if path == "" {
+		bot.renderLeaderboard(w, days, since)
+	} else if strings.HasPrefix(path, "player/") {
+		bot.renderPlayer(w, strings.TrimPrefix(path, "player/"), days, since)
+	} else {
+		http.NotFound(w, r)
+	}
+}
+
+// Returns the display name of each linked user, falling back to the
+// battleTag if the Discord user is unknown
+func (bot *Bot) getPlayerNames() map[string]string {
+	names := make(map[string]string)
+	for userId, playerState := range bot.getPlayerStates() {
+		if playerState.User != nil {
+			names[userId] = playerState.User.Username
+		} else {
+			names[userId] = playerState.BattleTag
+		}
+	}
+
+	return names
+}
+
+func (bot *Bot) renderLeaderboard(w http.ResponseWriter, days int, since time.Time) {
+	records, err := bot.store.GetAllSessions(since)
+	if err != nil {
+		bot.logger.WithError(err).Error("could not get sessions")
+		http.Error(w, "could not get sessions", http.StatusInternalServerError)
+		return
+	}
+	records = bot.getSharedSessions(records)
+
+	rows := make(map[string]*leaderboardRow)
+	for userId, name := range bot.getPlayerNames() {
+		privacy := bot.getPrivacy(userId)
+		if privacy.IsPrivate() {
+			continue
+		}
+		playerState, _ := bot.getPlayerState(userId)
+		row := &leaderboardRow{UserId: userId, Name: name, BattleTag: playerState.BattleTag}
+		if playerState.RegionBlob != nil && !privacy.HideSR() {
+			row.SR = playerState.RegionBlob.GetCompRank()
+		}
+		rows[userId] = row
+	}
+
+	// records are oldest first, so the last session sets the SR
+	for _, record := range records {
+		row, ok := rows[record.UserId]
+		if !ok {
+			continue
+		}
+
+		compWDL := record.CompWDL()
+		row.Sessions++
+		row.Hours += record.Duration().Hours()
+		row.CompWDL.Win += compWDL.Win
+		row.CompWDL.Draw += compWDL.Draw
+		row.CompWDL.Loss += compWDL.Loss
+		if record.FinalSR != 0 && !record.Alt {
+			row.SR = record.FinalSR
+		}
+	}
+
+	page := leaderboardPage{Days: days}
+	for _, row := range rows {
+		page.Rows = append(page.Rows, *row)
+	}
+	sort.Slice(page.Rows, func(i, j int) bool {
+		if page.Rows[i].SR != page.Rows[j].SR {
+			return page.Rows[i].SR > page.Rows[j].SR
+		}
+		return page.Rows[i].Name < page.Rows[j].Name
+	})
+
+	bot.renderDashboardTemplate(w, "leaderboard", page)
+}
+
+func (bot *Bot) renderPlayer(w http.ResponseWriter, userId string, days int, since time.Time) {
+	playerState, ok := bot.getPlayerState(userId)
+	privacy := bot.getPrivacy(userId)
+	if !ok || privacy.IsPrivate() {
+		http.Error(w, "player not found", http.StatusNotFound)
+		return
+	}
+
+	records, err := bot.store.GetSessions(userId, since)
+	if err != nil {
+		bot.logger.WithError(err).Error("could not get sessions")
+		http.Error(w, "could not get sessions", http.StatusInternalServerError)
+		return
+	}
+	records = bot.getSharedSessions(records)
+
+	page := playerPage{
+		Days:      days,
+		Name:      bot.getPlayerNames()[userId],
+		BattleTag: playerState.BattleTag,
+		SRHidden:  privacy.HideSR(),
+		Chart:     srChartSVG(records),
+	}
+	if playerState.RegionBlob != nil && !privacy.HideSR() {
+		page.SR = playerState.RegionBlob.GetCompRank()
+	}
+
+	heroesWDL := make(map[string]overwatch.WDL)
+	for i := len(records) - 1; i >= 0; i-- {
+		page.Sessions = append(page.Sessions, records[i])
+		for heroId, wdl := range records[i].HeroesWDL {
+			heroWDL := heroesWDL[heroId]
+			heroWDL.Win += wdl.Win
+			heroWDL.Draw += wdl.Draw
+			heroWDL.Loss += wdl.Loss
+			heroesWDL[heroId] = heroWDL
+		}
+	}
+	for _, heroId := range sortedHeroIds(heroesWDL) {
+		name := heroId
+		if hero, ok := overwatch.GetHero(heroId); ok {
+			name = hero.Name
+		}
+		page.Heroes = append(page.Heroes, heroRow{Name: name, WDL: heroesWDL[heroId]})
+	}
+
+	bot.renderDashboardTemplate(w, "player", page)
+}
+
+func (bot *Bot) renderDashboardTemplate(w http.ResponseWriter, name string, data interface{}) {
+	var page bytes.Buffer
+	if err := dashboardTemplates.ExecuteTemplate(&page, name, data); err != nil {
+		bot.logger.WithError(err).WithField("template", name).Error("Failed executing dashboard template")
+		http.Error(w, "could not render page", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	page.WriteTo(w)
+}
+
+const (
+	chartWidth   = 720
+	chartHeight  = 240
+	chartPadding = 40
+)
+
+// Renders the SR over time of the sessions as an inline SVG line chart.
+// Each session is drawn from its initial to its final SR, with the end
+// marked green for a gain and red for a loss. Seasons are drawn as separate
+// lines.
+func srChartSVG(records []store.SessionRecord) template.HTML {
+	var ranked []store.SessionRecord
+	for _, record := range records {
+		if record.InitialSR != 0 && record.FinalSR != 0 && !record.Alt {
+			ranked = append(ranked, record)
+		}
+	}
+	if len(ranked) == 0 {
+		return template.HTML("<p>No competitive sessions.</p>")
+	}
+
+	minTime, maxTime := ranked[0].Start, ranked[len(ranked)-1].End
+	minSR, maxSR := ranked[0].InitialSR, ranked[0].InitialSR
+	for _, record := range ranked {
+		for _, sr := range []int{record.InitialSR, record.FinalSR} {
+			if sr < minSR {
+				minSR = sr
+			}
+			if sr > maxSR {
+				maxSR = sr
+			}
+		}
+	}
+	// leave some room above and below the line
+	minSR -= 25
+	maxSR += 25
+
+	x := func(t time.Time) float64 {
+		span := maxTime.Sub(minTime).Seconds()
+		if span <= 0 {
+			return chartWidth / 2
+		}
+		return chartPadding + t.Sub(minTime).Seconds()/span*(chartWidth-2*chartPadding)
+	}
+	y := func(sr int) float64 {
+		return chartHeight - chartPadding - float64(sr-minSR)/float64(maxSR-minSR)*(chartHeight-2*chartPadding)
+	}
+
+	var svg bytes.Buffer
+	fmt.Fprintf(&svg, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`, chartWidth, chartHeight, chartWidth, chartHeight)
+	fmt.Fprintf(&svg, `<text x="4" y="%.1f" class="axis">%d</text>`, y(maxSR)+4, maxSR)
+	fmt.Fprintf(&svg, `<text x="4" y="%.1f" class="axis">%d</text>`, y(minSR)+4, minSR)
+	fmt.Fprintf(&svg, `<text x="%.1f" y="%d" class="axis">%s</text>`, x(minTime), chartHeight-8, minTime.Format("Jan 2"))
+	fmt.Fprintf(&svg, `<text x="%.1f" y="%d" class="axis" text-anchor="end">%s</text>`, x(maxTime), chartHeight-8, maxTime.Format("Jan 2"))
+
+	// each season gets its own line
+	svg.WriteString(`<polyline class="sr" points="`)
+	for i, record := range ranked {
+		if i > 0 && record.Season != ranked[i-1].Season {
+			fmt.Fprintf(&svg, `"/><line class="season" x1="%.1f" y1="%d" x2="%.1f" y2="%d"/><polyline class="sr" points="`,
+				x(record.Start), chartPadding, x(record.Start), chartHeight-chartPadding)
+		}
+		fmt.Fprintf(&svg, "%.1f,%.1f %.1f,%.1f ", x(record.Start), y(record.InitialSR), x(record.End), y(record.FinalSR))
+	}
+	svg.WriteString(`"/>`)
+
+	for _, record := range ranked {
+		class := "even"
+		if record.SRDiff() > 0 {
+			class = "gain"
+		} else if record.SRDiff() < 0 {
+			class = "loss"
+		}
+		fmt.Fprintf(&svg, `<circle class="%s" cx="%.1f" cy="%.1f" r="4"><title>%s: %d (%+d)</title></circle>`,
+			class, x(record.End), y(record.FinalSR), record.End.Format("Jan 2 15:04"), record.FinalSR, record.SRDiff())
+	}
+	svg.WriteString(`</svg>`)
+
+	return template.HTML(svg.String())
+}
@@ -0,0 +1,115 @@
+package owbot
+
+import (
+	"fmt"
+	"html/template"
+	"time"
+
+	"github.com/snakelayer/discord-oversessions/owbot/overwatch"
+)
+
+var dashboardFuncs = template.FuncMap{
+	"formatTime": func(t time.Time) string {
+		return t.Format("Mon Jan 2 15:04")
+	},
+	"formatDuration": formatHoursMinutes,
+	"signed":         templateFuncs["signed"],
+	// tierName 2734 => "Platinum", or "-" if unranked
+	"tierName": func(sr int) string {
+		if tier, ok := overwatch.GetTier(sr); ok {
+			return tier.Name
+		}
+		return "-"
+	},
+	"tierColor": func(sr int) template.CSS {
+		tier, _ := overwatch.GetTier(sr)
+		return template.CSS(fmt.Sprintf("#%06x", tier.Color))
+	},
+	"tierProgress": func(sr int) string {
+		progress, ok := overwatch.GetTierProgress(sr)
+		if !ok || progress.SRToNext == 0 {
+			return ""
+		}
+		return fmt.Sprintf("%d SR to %s (%d%%)", progress.SRToNext, progress.Next.Name, progress.Percent)
+	},
+}
+
+// The dashboard pages. Styles are inlined so the dashboard works without
+// access to any other host.
+var dashboardTemplates = template.Must(template.New("dashboard").Funcs(dashboardFuncs).Parse(`
+{{define "header"}}<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.}} - oversessions</title>
+<style>
+body { font-family: sans-serif; background: #1f2227; color: #dcddde; margin: 2em; }
+a { color: #7ab7ff; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { padding: 0.3em 0.8em; text-align: right; border-bottom: 1px solid #3a3d44; }
+th:first-child, td:first-child { text-align: left; }
+.gain { color: #43b581; fill: #43b581; }
+.loss { color: #f04747; fill: #f04747; }
+.even { fill: #dcddde; }
+.axis { fill: #8e9297; font-size: 12px; }
+polyline.sr { fill: none; stroke: #f99e1a; stroke-width: 2; }
+line.season { stroke: #8e9297; stroke-dasharray: 4; }
+</style>
+</head>
+<body>
+{{end}}
+
+{{define "footer"}}</body>
+</html>
+{{end}}
+
+{{define "srDiff"}}<span class="{{if gt . 0}}gain{{else if lt . 0}}loss{{end}}">{{signed .}}</span>{{end}}
+
+{{define "leaderboard"}}{{template "header" "Leaderboard"}}
+<h1>Leaderboard</h1>
+<p>Sessions in the last {{.Days}} days.</p>
+<table>
+<tr><th>Player</th><th>BattleTag</th><th>SR</th><th>Tier</th><th>Sessions</th><th>Hours</th><th>W</th><th>D</th><th>L</th><th>Win %</th></tr>
+{{range .Rows}}<tr>
+<td><a href="/dashboard/player/{{.UserId}}">{{.Name}}</a></td>
+<td>{{.BattleTag}}</td>
+<td>{{if .SR}}{{.SR}}{{else}}-{{end}}</td>
+<td style="color: {{tierColor .SR}}">{{tierName .SR}}</td>
+<td>{{.Sessions}}</td>
+<td>{{printf "%.1f" .Hours}}</td>
+<td>{{.CompWDL.Win}}</td>
+<td>{{.CompWDL.Draw}}</td>
+<td>{{.CompWDL.Loss}}</td>
+<td>{{.WinRate}}</td>
+</tr>{{end}}
+</table>
+{{template "footer"}}{{end}}
+
+{{define "player"}}{{template "header" .Name}}
+<p><a href="/dashboard/">Leaderboard</a></p>
+<h1>{{.Name}} <small>{{.BattleTag}}</small></h1>
+<p>{{if .SRHidden}}SR hidden{{else if .SR}}SR {{.SR}}, <span style="color: {{tierColor .SR}}">{{tierName .SR}}</span>{{with tierProgress .SR}}, {{.}}{{end}}{{else}}Unranked{{end}}. Sessions in the last {{.Days}} days.</p>
+<h2>SR</h2>
+{{.Chart}}
+<h2>Heroes</h2>
+<table>
+<tr><th>Hero</th><th>Games</th><th>W</th><th>D</th><th>L</th><th>Win %</th></tr>
+{{range .Heroes}}<tr>
+<td>{{.Name}}</td><td>{{.Games}}</td><td>{{.WDL.Win}}</td><td>{{.WDL.Draw}}</td><td>{{.WDL.Loss}}</td><td>{{.WinRate}}</td>
+</tr>{{else}}<tr><td colspan="6">No competitive games.</td></tr>{{end}}
+</table>
+<h2>Sessions</h2>
+<table>
+<tr><th>Start</th><th>Season</th><th>Length</th><th>W</th><th>D</th><th>L</th><th>Quickplay W-L</th><th>SR</th><th>Change</th></tr>
+{{range .Sessions}}{{$comp := .CompWDL}}<tr>
+<td>{{formatTime .Start}}</td>
+<td>{{if .Season}}{{.Season}}{{else}}-{{end}}</td>
+<td>{{formatDuration .Duration}}</td>
+<td>{{$comp.Win}}</td><td>{{$comp.Draw}}</td><td>{{$comp.Loss}}</td>
+<td>{{.QuickplayWDL.Win}}-{{.QuickplayWDL.Loss}}</td>
+<td>{{if .FinalSR}}{{.FinalSR}}{{else}}-{{end}}</td>
+<td>{{if .Placement}}{{if .FinalSR}}placed{{else}}placements{{end}}{{else}}{{template "srDiff" .SRDiff}}{{end}}</td>
+</tr>{{else}}<tr><td colspan="9">No sessions.</td></tr>{{end}}
+</table>
+{{template "footer"}}{{end}}
+`))
@@ -0,0 +1,195 @@
+package owbot
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/snakelayer/discord-oversessions/owbot/overwatch"
+	"github.com/snakelayer/discord-oversessions/owbot/store"
+)
+
+// Number of heroes listed as most played in a digest
+const digestTopHeroes = 3
+
+// Checks every minute whether a digest is due until stop is closed. The
+// schedule is read from the config on every check, so that it can be
+// changed by a reload.
+func (bot *Bot) runDigests(stop <-chan struct{}) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	lastCheck := time.Now()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			bot.postDueDigests(lastCheck, now)
+			lastCheck = now
+		}
+	}
+}
+
+// Posts the digests scheduled after lastCheck and up to now
+func (bot *Bot) postDueDigests(lastCheck time.Time, now time.Time) {
+	digestConfig := bot.getConfig().Digest
+	if !digestConfig.Daily && !digestConfig.Weekly {
+		return
+	}
+
+	// the config is validated on load, so these cannot fail
+	hour, minute, _ := digestConfig.Clock()
+	weekday, _ := digestConfig.ParseWeekday()
+	location, _ := digestConfig.Location()
+
+	local := now.In(location)
+	scheduled := time.Date(local.Year(), local.Month(), local.Day(), hour, minute, 0, 0, location)
+	if !scheduled.After(lastCheck) || scheduled.After(now) {
+		return
+	}
+
+	if digestConfig.Weekly && scheduled.Weekday() == weekday {
+		bot.postDigest("Weekly", scheduled.AddDate(0, 0, -7), scheduled)
+	}
+	if digestConfig.Daily {
+		bot.postDigest("Daily", scheduled.AddDate(0, 0, -1), scheduled)
+	}
+}
+
+// The sessions of one player during a digest period
+type digestPlayer struct {
+	Name     string
+	Duration time.Duration
+	SRDiff   int
+	Ranked   bool
+}
+
+func (bot *Bot) postDigest(kind string, start time.Time, end time.Time) {
+	logger := bot.logger.WithField("digest", kind).WithField("start", start)
+
+	records, err := bot.store.GetAllSessions(start)
+	if err != nil {
+		logger.WithError(err).Error("could not get sessions")
+		return
+	}
+	records = bot.getSharedSessions(records)
+
+	var periodRecords []store.SessionRecord
+	for _, record := range records {
+		if !record.End.After(end) {
+			periodRecords = append(periodRecords, record)
+		}
+	}
+	if len(periodRecords) == 0 {
+		logger.Info("no sessions to digest")
+		return
+	}
+
+	bot.discord.CreateMessage(bot.getDigestMessage(kind, start, end, periodRecords))
+	logger.WithField("sessions", len(periodRecords)).Info("posted digest")
+}
+
+// Summarizes the sessions: who played and for how long, who gained and lost
+// the most SR, the most played heroes and the W/D/L of everyone together
+func (bot *Bot) getDigestMessage(kind string, start time.Time, end time.Time, records []store.SessionRecord) string {
+	names := bot.getPlayerNames()
+	players := make(map[string]*digestPlayer)
+	heroGames := make(map[string]int)
+	var compWDL overwatch.WDL
+	var total time.Duration
+
+	for _, record := range records {
+		player, ok := players[record.UserId]
+		if !ok {
+			name, ok := names[record.UserId]
+			if !ok {
+				name = record.BattleTag
+			}
+			player = &digestPlayer{Name: name}
+			players[record.UserId] = player
+		}
+
+		player.Duration += record.Duration()
+		total += record.Duration()
+		// movers are ranked by the SR of their primary account only
+		if !record.Alt && record.InitialSR != 0 && record.FinalSR != 0 {
+			player.SRDiff += record.SRDiff()
+			player.Ranked = true
+		}
+
+		for heroId, wdl := range record.HeroesWDL {
+			heroGames[heroId] += wdl.Win + wdl.Draw + wdl.Loss
+			compWDL.Win += wdl.Win
+			compWDL.Draw += wdl.Draw
+			compWDL.Loss += wdl.Loss
+		}
+	}
+
+	var sortedPlayers []*digestPlayer
+	for _, player := range players {
+		sortedPlayers = append(sortedPlayers, player)
+	}
+	sort.Slice(sortedPlayers, func(i, j int) bool {
+		if sortedPlayers[i].Duration != sortedPlayers[j].Duration {
+			return sortedPlayers[i].Duration > sortedPlayers[j].Duration
+		}
+		return sortedPlayers[i].Name < sortedPlayers[j].Name
+	})
+
+	var buffer bytes.Buffer
+	fmt.Fprintf(&buffer, "**%s digest** (%s to %s)\n", kind, start.Format("Mon Jan 2 15:04"), end.Format("Mon Jan 2 15:04"))
+
+	var played []string
+	for _, player := range sortedPlayers {
+		played = append(played, fmt.Sprintf("**%s** (%s)", player.Name, formatHoursMinutes(player.Duration)))
+	}
+	fmt.Fprintf(&buffer, "Played: %s\n", strings.Join(played, ", "))
+	fmt.Fprintf(&buffer, "Total: %s over %d %s\n", formatHoursMinutes(total), len(records), plural(len(records), "session", "sessions"))
+
+	var gainer, loser *digestPlayer
+	for _, player := range sortedPlayers {
+		if !player.Ranked {
+			continue
+		}
+		if player.SRDiff > 0 && (gainer == nil || player.SRDiff > gainer.SRDiff) {
+			gainer = player
+		}
+		if player.SRDiff < 0 && (loser == nil || player.SRDiff < loser.SRDiff) {
+			loser = player
+		}
+	}
+	if gainer != nil {
+		fmt.Fprintf(&buffer, "Biggest gain: **%s** %+d SR\n", gainer.Name, gainer.SRDiff)
+	}
+	if loser != nil {
+		fmt.Fprintf(&buffer, "Biggest loss: **%s** %+d SR\n", loser.Name, loser.SRDiff)
+	}
+
+	var heroIds []string
+	for heroId, games := range heroGames {
+		if games > 0 {
+			heroIds = append(heroIds, heroId)
+		}
+	}
+	overwatch.SortHeroIds(heroIds, func(heroId string) int { return heroGames[heroId] })
+	if len(heroIds) > digestTopHeroes {
+		heroIds = heroIds[:digestTopHeroes]
+	}
+	if len(heroIds) > 0 {
+		sessionData := playerSessionData{HeroEmojis: bot.getHeroEmojis()}
+		var heroes []string
+		for _, heroId := range heroIds {
+			heroes = append(heroes, fmt.Sprintf("%s %d", sessionData.Emoji(heroId), heroGames[heroId]))
+		}
+		fmt.Fprintf(&buffer, "Most played: %s\n", strings.Join(heroes, ", "))
+	}
+
+	if !compWDL.IsEmpty() {
+		fmt.Fprintf(&buffer, "Comp: %d W / %d D / %d L (%d%% won)", compWDL.Win, compWDL.Draw, compWDL.Loss, winRate(compWDL))
+	}
+
+	return strings.TrimSuffix(buffer.String(), "\n")
+}
@@ -2,35 +2,78 @@ package discord
 
 import (
 	"errors"
+	"io"
 	"regexp"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/bwmarrin/discordgo"
+	"github.com/snakelayer/discord-oversessions/owbot/metrics"
 	"github.com/snakelayer/discord-oversessions/owbot/player"
 )
 
-var regexOverwatchChannel = regexp.MustCompile(`^over.*$`)
-
 type DiscordAdapter struct {
 	session   *discordgo.Session
 	guild     *discordgo.UserGuild
-	channel   *discordgo.Channel
 	ownUserId string
 
+	// serializes changes of the overwatch channel, which search the guild
+	// channels without holding mutex
+	channelMutex sync.Mutex
+	// guards channel, regexOverwatchChannel and overwatchChannelId, which
+	// can be changed by a reload or !setchannel
+	mutex   sync.RWMutex
+	channel *discordgo.Channel
+	// text channels matching this are used for reports and commands
+	regexOverwatchChannel *regexp.Regexp
+	// the channel picked by a guild admin, used instead of searching by
+	// regexOverwatchChannel if set
+	overwatchChannelId string
+
+	// 1 while connected to the gateway, accessed atomically
+	connected int32
+
 	logger *logrus.Entry
 }
 
-func New(logger *logrus.Logger, token string) (*DiscordAdapter, error) {
+func New(logger *logrus.Logger, token string, channelPattern string) (*DiscordAdapter, error) {
+	regexOverwatchChannel, err := regexp.Compile(channelPattern)
+	if err != nil {
+		return nil, err
+	}
+
 	session, err := discordgo.New("Bot " + token)
 	if err != nil {
 		return nil, err
 	}
 
-	return &DiscordAdapter{
-		session: session,
-		logger:  logger.WithField("module", "discord"),
-	}, nil
+	discordAdapter := &DiscordAdapter{
+		session:               session,
+		regexOverwatchChannel: regexOverwatchChannel,
+		logger:                logger.WithField("module", "discord"),
+	}
+	session.AddHandler(discordAdapter.connectHandler)
+	session.AddHandler(discordAdapter.disconnectHandler)
+
+	return discordAdapter, nil
+}
+
+func (discordAdapter *DiscordAdapter) connectHandler(session *discordgo.Session, connect *discordgo.Connect) {
+	atomic.StoreInt32(&discordAdapter.connected, 1)
+	metrics.DiscordConnected.Set(1)
+	discordAdapter.logger.Info("connected to gateway")
+}
+
+func (discordAdapter *DiscordAdapter) disconnectHandler(session *discordgo.Session, disconnect *discordgo.Disconnect) {
+	atomic.StoreInt32(&discordAdapter.connected, 0)
+	metrics.DiscordConnected.Set(0)
+	discordAdapter.logger.Warn("disconnected from gateway")
+}
+
+func (discordAdapter *DiscordAdapter) IsConnected() bool {
+	return atomic.LoadInt32(&discordAdapter.connected) == 1
 }
 
 func (discordAdapter *DiscordAdapter) Connect() error {
@@ -42,6 +85,10 @@ func (discordAdapter *DiscordAdapter) AddHandler(handler interface{}) {
 }
 
 func (discordAdapter *DiscordAdapter) SetPlayerState(userId string, playerState *player.PlayerState) {
+	if discordAdapter.guild == nil {
+		return
+	}
+
 	presence, err := discordAdapter.session.State.Presence(discordAdapter.guild.ID, userId)
 	if err != nil {
 		discordAdapter.logger.WithError(err).Error("could not get player presence")
@@ -123,11 +170,39 @@ func (discordAdapter *DiscordAdapter) SetGuildAndOverwatchChannel() {
 	discordAdapter.logger.WithField("guild", *guilds[0]).Debug("guild data")
 	discordAdapter.guild = guilds[0]
 
-	channels, err := discordAdapter.session.GuildChannels(guilds[0].ID)
-	if err != nil {
+	discordAdapter.channelMutex.Lock()
+	defer discordAdapter.channelMutex.Unlock()
+
+	discordAdapter.mutex.RLock()
+	overwatchChannelId := discordAdapter.overwatchChannelId
+	regexOverwatchChannel := discordAdapter.regexOverwatchChannel
+	discordAdapter.mutex.RUnlock()
+
+	overwatchChannel := discordAdapter.findOverwatchChannel(overwatchChannelId, regexOverwatchChannel)
+	if overwatchChannel == nil {
 		return
 	}
+	discordAdapter.mutex.Lock()
+	discordAdapter.channel = overwatchChannel
+	discordAdapter.mutex.Unlock()
+}
+
+// Returns the guild text channel with the id overwatchChannelId if there is
+// one, else the first channel matching regexOverwatchChannel, else the
+// first channel. Returns nil if the guild has no text channel.
+func (discordAdapter *DiscordAdapter) findOverwatchChannel(overwatchChannelId string, regexOverwatchChannel *regexp.Regexp) *discordgo.Channel {
+	channels, err := discordAdapter.session.GuildChannels(discordAdapter.guild.ID)
+	if err != nil {
+		return nil
+	}
 
+	var overwatchChannel *discordgo.Channel
+	for _, channel := range channels {
+		if channel.ID == overwatchChannelId && channel.Type == discordgo.ChannelTypeGuildText {
+			discordAdapter.logger.WithField("channelId", channel.ID).WithField("channelName", channel.Name).Debug("found picked overwatch channel")
+			return channel
+		}
+	}
 	for _, channel := range channels {
 		discordAdapter.logger.WithField("channel", channel).Debug("channel data")
 		if channel.Type == 2 {
@@ -136,30 +211,227 @@ func (discordAdapter *DiscordAdapter) SetGuildAndOverwatchChannel() {
 
 		if regexOverwatchChannel.MatchString(channel.Name) {
 			discordAdapter.logger.WithField("channelId", channel.ID).WithField("channelName", channel.Name).Debug("found overwatch channel")
-			discordAdapter.channel = channel
-			return
+			overwatchChannel = channel
+			break
 		}
 
-		if discordAdapter.channel == nil {
-			discordAdapter.channel = channel
+		if overwatchChannel == nil {
+			overwatchChannel = channel
 		}
 	}
 
-	if discordAdapter.channel == nil {
+	if overwatchChannel == nil {
 		discordAdapter.logger.Error("no text channel found")
 	}
+	return overwatchChannel
+}
+
+// Changes the pattern used to find the overwatch channel, and searches
+// for the channel again
+func (discordAdapter *DiscordAdapter) SetChannelPattern(channelPattern string) error {
+	regexOverwatchChannel, err := regexp.Compile(channelPattern)
+	if err != nil {
+		return err
+	}
+
+	discordAdapter.channelMutex.Lock()
+	defer discordAdapter.channelMutex.Unlock()
+
+	discordAdapter.mutex.Lock()
+	discordAdapter.regexOverwatchChannel = regexOverwatchChannel
+	overwatchChannelId := discordAdapter.overwatchChannelId
+	discordAdapter.mutex.Unlock()
+
+	if discordAdapter.guild == nil {
+		return nil
+	}
+
+	overwatchChannel := discordAdapter.findOverwatchChannel(overwatchChannelId, regexOverwatchChannel)
+	if overwatchChannel != nil {
+		discordAdapter.mutex.Lock()
+		discordAdapter.channel = overwatchChannel
+		discordAdapter.mutex.Unlock()
+	}
+	return nil
+}
+
+// Uses the channel for reports and commands instead of searching by the
+// channel pattern. An empty channelId goes back to the channel pattern.
+func (discordAdapter *DiscordAdapter) SetOverwatchChannel(channelId string) error {
+	discordAdapter.channelMutex.Lock()
+	defer discordAdapter.channelMutex.Unlock()
+
+	if discordAdapter.guild == nil {
+		discordAdapter.mutex.Lock()
+		discordAdapter.overwatchChannelId = channelId
+		discordAdapter.mutex.Unlock()
+		return nil
+	}
+
+	discordAdapter.mutex.RLock()
+	regexOverwatchChannel := discordAdapter.regexOverwatchChannel
+	discordAdapter.mutex.RUnlock()
+
+	overwatchChannel := discordAdapter.findOverwatchChannel(channelId, regexOverwatchChannel)
+	if channelId != "" && (overwatchChannel == nil || overwatchChannel.ID != channelId) {
+		return errors.New("no text channel " + channelId + " in the guild")
+	}
+
+	discordAdapter.mutex.Lock()
+	discordAdapter.overwatchChannelId = channelId
+	if overwatchChannel != nil {
+		discordAdapter.channel = overwatchChannel
+	}
+	discordAdapter.mutex.Unlock()
+	return nil
+}
+
+func (discordAdapter *DiscordAdapter) GetGuildId() string {
+	if discordAdapter.guild == nil {
+		return ""
+	}
+	return discordAdapter.guild.ID
+}
+
+// Returns the custom emoji of the guild, by emoji name, formatted for use
+// in messages
+func (discordAdapter *DiscordAdapter) GetGuildEmojis() map[string]string {
+	emojis := make(map[string]string)
+	if discordAdapter.guild == nil {
+		return emojis
+	}
+
+	guild, err := discordAdapter.session.State.Guild(discordAdapter.guild.ID)
+	if err != nil {
+		discordAdapter.logger.WithError(err).WithField("guildId", discordAdapter.guild.ID).Error("could not get guild emojis")
+		return emojis
+	}
+
+	for _, emoji := range guild.Emojis {
+		emojis[emoji.Name] = "<:" + emoji.Name + ":" + emoji.ID + ">"
+	}
+	return emojis
+}
+
+// Checks if the user can manage the guild, which is required to change
+// guild wide settings
+func (discordAdapter *DiscordAdapter) IsGuildAdmin(userId string) bool {
+	channelId := discordAdapter.GetOverwatchChannelId()
+	if channelId == "" {
+		return false
+	}
+
+	permissions, err := discordAdapter.session.UserChannelPermissions(userId, channelId)
+	if err != nil {
+		discordAdapter.logger.WithError(err).WithField("userId", userId).Error("could not get user permissions")
+		return false
+	}
+
+	return permissions&(discordgo.PermissionAdministrator|discordgo.PermissionManageServer) != 0
+}
+
+// Returns the names of the guild roles of the user
+func (discordAdapter *DiscordAdapter) GetMemberRoleNames(userId string) []string {
+	if discordAdapter.guild == nil {
+		return nil
+	}
+
+	member, err := discordAdapter.session.State.Member(discordAdapter.guild.ID, userId)
+	if err != nil {
+		member, err = discordAdapter.session.GuildMember(discordAdapter.guild.ID, userId)
+	}
+	if err != nil {
+		discordAdapter.logger.WithError(err).WithField("userId", userId).Error("could not get guild member")
+		return nil
+	}
+
+	var roleNames []string
+	for _, roleId := range member.Roles {
+		role, err := discordAdapter.session.State.Role(discordAdapter.guild.ID, roleId)
+		if err != nil {
+			discordAdapter.logger.WithError(err).WithField("roleId", roleId).Debug("could not get role")
+			continue
+		}
+		roleNames = append(roleNames, role.Name)
+	}
+	return roleNames
 }
 
+// Returns the id of the channel used for reports and commands, or "" if
+// none was found yet
 func (discordAdapter *DiscordAdapter) GetOverwatchChannelId() string {
+	discordAdapter.mutex.RLock()
+	defer discordAdapter.mutex.RUnlock()
+	if discordAdapter.channel == nil {
+		return ""
+	}
 	return discordAdapter.channel.ID
 }
 
 func (discordAdapter *DiscordAdapter) CreateMessage(content string) (m *discordgo.Message, err error) {
-	if discordAdapter.channel.ID == "" {
+	channelId := discordAdapter.GetOverwatchChannelId()
+	if channelId == "" {
+		return nil, errors.New("no text channel for message sending")
+	}
+
+	return discordAdapter.session.ChannelMessageSend(channelId, content)
+}
+
+func (discordAdapter *DiscordAdapter) CreateEmbed(embed *discordgo.MessageEmbed) (m *discordgo.Message, err error) {
+	channelId := discordAdapter.GetOverwatchChannelId()
+	if channelId == "" {
+		return nil, errors.New("no text channel for message sending")
+	}
+
+	return discordAdapter.session.ChannelMessageSendEmbed(channelId, embed)
+}
+
+// Sends a message to the user by direct message
+func (discordAdapter *DiscordAdapter) CreateDirectMessage(userId string, content string) (m *discordgo.Message, err error) {
+	channel, err := discordAdapter.session.UserChannelCreate(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return discordAdapter.session.ChannelMessageSend(channel.ID, content)
+}
+
+// Checks if the channel is a direct message channel with a user
+func (discordAdapter *DiscordAdapter) IsDirectMessageChannel(channelId string) bool {
+	channel, err := discordAdapter.session.State.Channel(channelId)
+	if err != nil {
+		// direct message channels are not always in the state cache
+		channel, err = discordAdapter.session.Channel(channelId)
+	}
+	if err != nil {
+		discordAdapter.logger.WithError(err).WithField("channelId", channelId).Debug("could not get channel")
+		return false
+	}
+
+	return channel.Type == discordgo.ChannelTypeDM
+}
+
+// Sends a message to the given channel, eg. in reply to a direct message
+func (discordAdapter *DiscordAdapter) SendMessage(channelId string, content string) (m *discordgo.Message, err error) {
+	return discordAdapter.session.ChannelMessageSend(channelId, content)
+}
+
+func (discordAdapter *DiscordAdapter) SendEmbed(channelId string, embed *discordgo.MessageEmbed) (m *discordgo.Message, err error) {
+	return discordAdapter.session.ChannelMessageSendEmbed(channelId, embed)
+}
+
+func (discordAdapter *DiscordAdapter) SendMessageWithFile(channelId string, content string, name string, file io.Reader) (m *discordgo.Message, err error) {
+	return discordAdapter.session.ChannelFileSendWithMessage(channelId, content, name, file)
+}
+
+// Sends a message with the file attached under the given name
+func (discordAdapter *DiscordAdapter) CreateMessageWithFile(content string, name string, file io.Reader) (m *discordgo.Message, err error) {
+	channelId := discordAdapter.GetOverwatchChannelId()
+	if channelId == "" {
 		return nil, errors.New("no text channel for message sending")
 	}
 
-	return discordAdapter.session.ChannelMessageSend(discordAdapter.channel.ID, content)
+	return discordAdapter.session.ChannelFileSendWithMessage(channelId, content, name, file)
 }
 
 func (discordAdapter *DiscordAdapter) UpdateMessage(messageId string, content string) (m *discordgo.Message, err error) {
@@ -167,11 +439,12 @@ func (discordAdapter *DiscordAdapter) UpdateMessage(messageId string, content st
 		return nil, errors.New("missing messageId")
 	}
 
-	if discordAdapter.channel.ID == "" {
+	channelId := discordAdapter.GetOverwatchChannelId()
+	if channelId == "" {
 		return nil, errors.New("no text channel for message sending")
 	}
 
-	return discordAdapter.session.ChannelMessageEdit(discordAdapter.channel.ID, messageId, content)
+	return discordAdapter.session.ChannelMessageEdit(channelId, messageId, content)
 }
 
 func (discordAdapter *DiscordAdapter) ReadMessage(messageId string) (m *discordgo.Message, err error) {
@@ -179,11 +452,12 @@ func (discordAdapter *DiscordAdapter) ReadMessage(messageId string) (m *discordg
 		return nil, errors.New("missing messageId")
 	}
 
-	if discordAdapter.channel.ID == "" {
+	channelId := discordAdapter.GetOverwatchChannelId()
+	if channelId == "" {
 		return nil, errors.New("no text channel for message sending")
 	}
 
-	return discordAdapter.session.ChannelMessage(discordAdapter.channel.ID, messageId)
+	return discordAdapter.session.ChannelMessage(channelId, messageId)
 }
 
 func (discordAdapter *DiscordAdapter) IsOverwatch(game *discordgo.Game) bool {
@@ -0,0 +1,156 @@
+package owbot
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/snakelayer/discord-oversessions/owbot/overwatch"
+)
+
+// Matches anything that is not part of a hero id
+var regexNonHeroIdChars = regexp.MustCompile(`[^a-z0-9]`)
+
+// A custom emoji as written in a message
+var regexCustomEmoji = regexp.MustCompile(`^<a?:\w+:\d+>$`)
+
+// Returns the emoji used for each hero in the guild. Guild emoji named
+// after a hero (eg. "soldier_76" or "Soldier76") are used automatically,
+// and can be overridden with !emoji set. Heroes without an emoji are
+// missing from the map.
+func (bot *Bot) getHeroEmojis() map[string]string {
+	return bot.getEmojis(func(id string) bool {
+		_, ok := overwatch.GetHero(id)
+		return ok
+	})
+}
+
+// Returns the emoji used for each rank tier in the guild, by tier id, found
+// the same way as hero emoji
+func (bot *Bot) getTierEmojis() map[string]string {
+	return bot.getEmojis(func(id string) bool {
+		_, ok := overwatch.GetTierById(id)
+		return ok
+	})
+}
+
+// Returns the guild emoji whose normalized name is an id accepted by isId,
+// overlaid with the overrides stored for those ids
+func (bot *Bot) getEmojis(isId func(id string) bool) map[string]string {
+	emojis := make(map[string]string)
+
+	for name, emoji := range bot.discord.GetGuildEmojis() {
+		id := regexNonHeroIdChars.ReplaceAllString(strings.ToLower(name), "")
+		if isId(id) {
+			emojis[id] = emoji
+		}
+	}
+
+	settings, err := bot.store.GetGuildSettings(bot.discord.GetGuildId())
+	if err != nil {
+		bot.logger.WithError(err).Error("could not get guild settings")
+		return emojis
+	}
+	for id, emoji := range settings.Emojis {
+		if isId(id) {
+			emojis[id] = emoji
+		}
+	}
+
+	return emojis
+}
+
+const emojiUsage = "usage: `!emoji list|set <hero or tier> <emoji>|reset <hero or tier>`"
+
+// Handles the !emoji command. Changing emoji requires the user to be a
+// guild admin.
+func (bot *Bot) emojiCommand(message *discordgo.Message, args string) {
+	bot.logger.WithField("user", message.Author).WithField("args", args).Info("emoji request")
+
+	input := regexWhitespace.Split(strings.TrimSpace(args), 3)
+	switch {
+	case input[0] == "list":
+		bot.listHeroEmojis()
+	case input[0] == "set" && len(input) == 3:
+		if !bot.hasPermission(message, actionChangeEmoji) {
+			return
+		}
+		bot.setGuildEmoji(input[1], input[2])
+	case input[0] == "reset" && len(input) == 2:
+		if !bot.hasPermission(message, actionChangeEmoji) {
+			return
+		}
+		bot.setGuildEmoji(input[1], "")
+	default:
+		bot.discord.CreateMessage(emojiUsage)
+	}
+}
+
+func (bot *Bot) listHeroEmojis() {
+	sessionData := playerSessionData{HeroEmojis: bot.getHeroEmojis()}
+
+	var buffer bytes.Buffer
+	buffer.WriteString("hero emoji:")
+	for _, hero := range overwatch.Heroes {
+		buffer.WriteString("\n" + hero.Id + ": " + sessionData.Emoji(hero.Id))
+	}
+
+	tierEmojis := bot.getTierEmojis()
+	buffer.WriteString("\nrank tier emoji:")
+	for _, tier := range overwatch.Tiers {
+		emoji, ok := tierEmojis[tier.Id()]
+		if !ok {
+			emoji = "none"
+		}
+		buffer.WriteString("\n" + tier.Id() + ": " + emoji)
+	}
+	bot.discord.CreateMessage(buffer.String())
+}
+
+// Stores the emoji for a hero or rank tier. An empty emoji removes the
+// override, going back to the auto-discovered emoji or abbreviation.
+func (bot *Bot) setGuildEmoji(id string, emoji string) {
+	id = strings.ToLower(id)
+	_, isHero := overwatch.GetHero(id)
+	_, isTier := overwatch.GetTierById(id)
+	if !isHero && !isTier {
+		bot.discord.CreateMessage(id + " is not a hero or rank tier, try `!emoji list`")
+		return
+	}
+	if emoji != "" && !regexCustomEmoji.MatchString(emoji) && len([]rune(emoji)) > 2 {
+		bot.discord.CreateMessage(emoji + " is not an emoji")
+		return
+	}
+
+	guildId := bot.discord.GetGuildId()
+	settings, err := bot.store.GetGuildSettings(guildId)
+	if err != nil {
+		bot.logger.WithError(err).Error("could not get guild settings")
+		return
+	}
+
+	if emoji == "" {
+		delete(settings.Emojis, id)
+	} else {
+		settings.Emojis[id] = emoji
+	}
+
+	if err := bot.store.PutGuildSettings(guildId, settings); err != nil {
+		bot.logger.WithError(err).Error("could not store guild settings")
+		bot.discord.CreateMessage("could not save emoji for " + id)
+		return
+	}
+
+	if isTier {
+		emoji, ok := bot.getTierEmojis()[id]
+		if !ok {
+			emoji = "no emoji"
+		}
+		bot.discord.CreateMessage(id + " is now shown with " + emoji)
+		return
+	}
+
+	sessionData := playerSessionData{HeroEmojis: bot.getHeroEmojis()}
+	bot.discord.CreateMessage(id + " is now shown as " + sessionData.Emoji(id))
+}
@@ -3,6 +3,8 @@ package owbot
 import (
 	"bytes"
 	"context"
+	"fmt"
+	"io"
 	"regexp"
 	"strings"
 	"text/template"
@@ -10,62 +12,129 @@ import (
 
 	"github.com/Sirupsen/logrus"
 	"github.com/bwmarrin/discordgo"
+	"github.com/snakelayer/discord-oversessions/owbot/metrics"
 	"github.com/snakelayer/discord-oversessions/owbot/overwatch"
 	"github.com/snakelayer/discord-oversessions/owbot/player"
+	"github.com/snakelayer/discord-oversessions/owbot/store"
 )
 
-const (
-	// Longest amount of time a command is processed until given up on
-	commandTimeout     = 10 * time.Second
-	longCommandTimeout = 30 * time.Second
-
-	maxGetUserStatsAttempts = 10
-)
-
-// are these guild specific?
-var HeroEmojiMap = map[string]string{
-	"ana":        "<:ana:303409414151340035> ",
-	"bastion":    "<:bastion:303409414554255360>",
-	"dva":        "<:dva:303409415107772416>",
-	"genji":      "<:genji:303409415187333130>",
-	"hanzo":      "<:hanzo:303409414776422412>",
-	"junkrat":    "<:junkrat:303409415112097792>",
-	"lucio":      "<:lucio:303409415422476289>",
-	"mccree":     "<:mccree:303409414780747786>",
-	"mei":        "<:mei:303409415317356544>",
-	"mercy":      "<:mercy:303409415346978818>",
-	"orisa":      "<:orisa:303409418207232000>",
-	"pharah":     "<:pharah:303409415065960450>",
-	"reaper":     "<:reaper:303409414487015425>",
-	"reinhardt":  "<:reinhardt:303409415011303425>",
-	"roadhog":    "<:roadhog:303409415409762315>",
-	"soldier76":  "<:soldier_76:303409415069892609>",
-	"sombra":     "<:sombra:304543090541068289>",
-	"symmetra":   "<:symmetra:303409415787380736>",
-	"torbjorn":   "<:torbjorn:303409415514619904>",
-	"tracer":     "<:tracer:303409415581859840>",
-	"widowmaker": "<:widowmaker:303409415480934400>",
-	"winston":    "<:winston:303409414822690821>",
-	"zarya":      "<:zarya:303409415472676874>",
-	"zenyatta":   "<:zenyatta:303409415166623745>",
-}
-
 type playerSessionData struct {
-	Username string
-	FinalSR  int
-	SRDiff   int
+	Username  string
+	BattleTag string
+	InitialSR int
+	FinalSR   int
+	SRDiff    int
 
 	Hours   int
 	Minutes int
 
 	HeroesWDL    map[string]overwatch.WDL
 	QuickplayWDL overwatch.WDL
+	// Quickplay wins by hero, and losses where they are tracked
+	QuickplayHeroesWDL map[string]overwatch.WDL
+
+	// A new comp season started during the session
+	NewSeason bool
+
+	// The player hides their SR, so every SR field is zero
+	SRHidden bool
+
+	// Comp game stats of the session, with zero Games if there were no
+	// comp games
+	Performance overwatch.GameStatsDiff
+
+	// Comp matches inferred from the stats fetched during the session
+	Matches []overwatch.Match
+
+	// The battleTag the session was played on, set only if the player has
+	// several accounts, and whether it is an alt account
+	Account string
+	Alt     bool
+
+	// Emoji of the reporting guild by hero id and by rank tier id
+	HeroEmojis map[string]string
+	TierEmojis map[string]string
+}
+
+// Returns the guild's emoji for the hero, or the hero's abbreviation if
+// the guild has none
+func (sessionData playerSessionData) Emoji(heroId string) string {
+	if emoji, ok := sessionData.HeroEmojis[heroId]; ok {
+		return emoji
+	}
+
+	if hero, ok := overwatch.GetHero(heroId); ok {
+		return "[" + hero.Abbreviation + "]"
+	}
+	return "[" + heroId + "]"
 }
 
 func (sessionData playerSessionData) HasSRChange() bool {
 	return sessionData.SRDiff != 0
 }
 
+// Checks if the session included placement matches, which are played
+// without an SR
+func (sessionData playerSessionData) IsPlacement() bool {
+	return !sessionData.SRHidden && sessionData.InitialSR == 0 && sessionData.CompGames() > 0
+}
+
+// Checks if the player finished their placement matches in the session
+func (sessionData playerSessionData) HasPlaced() bool {
+	return sessionData.IsPlacement() && sessionData.FinalSR != 0
+}
+
+// Returns the name of the rank tier of the SR at the start of the session,
+// or an empty string if unranked
+func (sessionData playerSessionData) InitialTier() string {
+	tier, _ := overwatch.GetTier(sessionData.InitialSR)
+	return tier.Name
+}
+
+func (sessionData playerSessionData) FinalTier() string {
+	tier, _ := overwatch.GetTier(sessionData.FinalSR)
+	return tier.Name
+}
+
+// Returns the guild's emoji for the final rank tier, or an empty string if
+// the guild has none
+func (sessionData playerSessionData) TierIcon() string {
+	tier, ok := overwatch.GetTier(sessionData.FinalSR)
+	if !ok {
+		return ""
+	}
+	return sessionData.TierEmojis[tier.Id()]
+}
+
+// Describes a change of rank tier during the session, eg. "promoted to
+// Diamond", or returns an empty string if the tier did not change
+func (sessionData playerSessionData) TierChange() string {
+	initial, initialOk := overwatch.GetTier(sessionData.InitialSR)
+	final, finalOk := overwatch.GetTier(sessionData.FinalSR)
+	if !initialOk || !finalOk || initial.Name == final.Name {
+		return ""
+	}
+
+	if final.MinSR > initial.MinSR {
+		return "promoted to " + final.Name
+	}
+	return "demoted to " + final.Name
+}
+
+// Describes the SR needed to reach the next tier, eg. "266 SR to Diamond",
+// or returns an empty string if unranked or in the highest tier
+func (sessionData playerSessionData) TierProgress() string {
+	progress, ok := overwatch.GetTierProgress(sessionData.FinalSR)
+	if !ok || progress.SRToNext == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d SR to %s", progress.SRToNext, progress.Next.Name)
+}
+
+func (sessionData playerSessionData) HasPerformance() bool {
+	return sessionData.Performance.Games > 0
+}
+
 func (sessionData playerSessionData) HasWins() bool {
 	for _, wdl := range sessionData.HeroesWDL {
 		if wdl.Win != 0 {
@@ -77,20 +146,26 @@ func (sessionData playerSessionData) HasWins() bool {
 }
 
 func (sessionData playerSessionData) WinString() string {
-	var buffer bytes.Buffer
+	return sessionData.emojiString(sessionData.HeroesWDL, func(wdl overwatch.WDL) int { return wdl.Win })
+}
 
-	for hero, wdl := range sessionData.HeroesWDL {
-		for i := 0; i < wdl.Win; i++ {
-			buffer.WriteString(HeroEmojiMap[hero])
+func (sessionData playerSessionData) HasDraws() bool {
+	for _, wdl := range sessionData.HeroesWDL {
+		if wdl.Draw != 0 {
+			return true
 		}
 	}
 
-	return buffer.String()
+	return false
 }
 
-func (sessionData playerSessionData) HasDraws() bool {
+func (sessionData playerSessionData) DrawString() string {
+	return sessionData.emojiString(sessionData.HeroesWDL, func(wdl overwatch.WDL) int { return wdl.Draw })
+}
+
+func (sessionData playerSessionData) HasLosses() bool {
 	for _, wdl := range sessionData.HeroesWDL {
-		if wdl.Draw != 0 {
+		if wdl.Loss != 0 {
 			return true
 		}
 	}
@@ -98,21 +173,27 @@ func (sessionData playerSessionData) HasDraws() bool {
 	return false
 }
 
-func (sessionData playerSessionData) DrawString() string {
-	var buffer bytes.Buffer
+func (sessionData playerSessionData) LossString() string {
+	return sessionData.emojiString(sessionData.HeroesWDL, func(wdl overwatch.WDL) int { return wdl.Loss })
+}
 
-	for hero, wdl := range sessionData.HeroesWDL {
-		for i := 0; i < wdl.Draw; i++ {
-			buffer.WriteString(HeroEmojiMap[hero])
+func (sessionData playerSessionData) HasQuickplayWins() bool {
+	for _, wdl := range sessionData.QuickplayHeroesWDL {
+		if wdl.Win > 0 {
+			return true
 		}
 	}
 
-	return buffer.String()
+	return false
 }
 
-func (sessionData playerSessionData) HasLosses() bool {
-	for _, wdl := range sessionData.HeroesWDL {
-		if wdl.Loss != 0 {
+func (sessionData playerSessionData) QuickplayWinString() string {
+	return sessionData.emojiString(sessionData.QuickplayHeroesWDL, func(wdl overwatch.WDL) int { return wdl.Win })
+}
+
+func (sessionData playerSessionData) HasQuickplayLosses() bool {
+	for _, wdl := range sessionData.QuickplayHeroesWDL {
+		if wdl.Loss > 0 {
 			return true
 		}
 	}
@@ -120,42 +201,120 @@ func (sessionData playerSessionData) HasLosses() bool {
 	return false
 }
 
-func (sessionData playerSessionData) LossString() string {
+func (sessionData playerSessionData) QuickplayLossString() string {
+	return sessionData.emojiString(sessionData.QuickplayHeroesWDL, func(wdl overwatch.WDL) int { return wdl.Loss })
+}
+
+// Repeats the emoji of each hero by its count of games, in the order of
+// sortedHeroIds
+func (sessionData playerSessionData) emojiString(heroesWDL map[string]overwatch.WDL, count func(overwatch.WDL) int) string {
 	var buffer bytes.Buffer
 
-	for hero, wdl := range sessionData.HeroesWDL {
-		for i := 0; i < wdl.Loss; i++ {
-			buffer.WriteString(HeroEmojiMap[hero])
+	for _, hero := range sortedHeroIds(heroesWDL) {
+		for i := 0; i < count(heroesWDL[hero]); i++ {
+			buffer.WriteString(sessionData.Emoji(hero))
 		}
 	}
 
 	return buffer.String()
 }
 
+// Returns the ids of the heroes with games, ordered by overwatch.SortHeroIds
+// on their total games
+func sortedHeroIds(heroesWDL map[string]overwatch.WDL) []string {
+	var heroIds []string
+	for heroId, wdl := range heroesWDL {
+		if !wdl.IsEmpty() {
+			heroIds = append(heroIds, heroId)
+		}
+	}
+	overwatch.SortHeroIds(heroIds, func(heroId string) int {
+		wdl := heroesWDL[heroId]
+		return wdl.Win + wdl.Draw + wdl.Loss
+	})
+
+	return heroIds
+}
+
+// Whether the order of the comp matches is known, which needs them to have
+// shown up in different snapshots. A single match is always in order.
+func (sessionData playerSessionData) HasMatchTimeline() bool {
+	for _, match := range sessionData.Matches {
+		if !match.Time.Equal(sessionData.Matches[0].Time) {
+			return true
+		}
+	}
+
+	return len(sessionData.Matches) == 1
+}
+
+// The comp matches in the order they were played, eg.
+// "W (+24) Mercy, L (-26) Ana, D Lúcio"
+func (sessionData playerSessionData) MatchTimeline() string {
+	var matches []string
+	for _, match := range sessionData.Matches {
+		var heroNames []string
+		for _, heroId := range match.Heroes {
+			if hero, ok := overwatch.GetHero(heroId); ok {
+				heroNames = append(heroNames, hero.Name)
+			} else {
+				heroNames = append(heroNames, heroId)
+			}
+		}
+
+		text := string(match.Result)
+		if match.SRKnown {
+			text += fmt.Sprintf(" (%+d)", match.SRDiff)
+		}
+		if len(heroNames) > 0 {
+			text += " " + strings.Join(heroNames, "/")
+		}
+		matches = append(matches, text)
+	}
+
+	return strings.Join(matches, ", ")
+}
+
 func (sessionData playerSessionData) IsEmptyQuickplay() bool {
 	return sessionData.QuickplayWDL.IsEmpty()
 }
 
-var templateDiffMessage = template.Must(template.New("DiffMessage").Parse(strings.TrimSpace(`
-**{{ .Username }}**:
-session length: {{if (gt .Hours 0)}}{{ .Hours }} {{if (eq .Hours 1)}}hr{{else}}hrs{{end}} {{end}}{{ .Minutes }} min{{if not .IsEmptyQuickplay}}
-quickplay: {{.QuickplayWDL.Win}} {{if (eq .QuickplayWDL.Win 1)}}win{{else}}wins{{end}}, {{.QuickplayWDL.Loss}} {{if (eq .QuickplayWDL.Loss 1)}}loss{{else}}losses{{end}}{{end}}{{if .HasWins}}
-comp wins: {{.WinString}}{{end}}{{if .HasDraws}}
-comp draws: {{.DrawString}}{{end}}{{if .HasLosses}}
-comp losses: {{.LossString}}{{end}}{{if .HasSRChange}}
-SR: {{ .FinalSR }} ({{if (ge .SRDiff 0)}}+{{end}}{{ .SRDiff }}){{end}}
-`)))
+// The comp results of the session, summed over all heroes
+func (sessionData playerSessionData) CompWDL() overwatch.WDL {
+	var compWDL overwatch.WDL
+	for _, wdl := range sessionData.HeroesWDL {
+		compWDL.Win += wdl.Win
+		compWDL.Draw += wdl.Draw
+		compWDL.Loss += wdl.Loss
+	}
+
+	return compWDL
+}
+
+func (sessionData playerSessionData) CompGames() int {
+	compWDL := sessionData.CompWDL()
+	return compWDL.Win + compWDL.Draw + compWDL.Loss
+}
+
+// The comp results of the session by role
+func (sessionData playerSessionData) Roles() []roleResult {
+	return getRoleResults(sessionData.HeroesWDL, sessionData.Matches)
+}
+
+func (sessionData playerSessionData) HasRoles() bool {
+	return len(sessionData.Roles()) > 0
+}
 
-var templateNoChangeMessage = template.Must(template.New("NoChangeMessage").Parse(strings.TrimSpace(`
-**{{ .User.Username }}**: SR {{ .RegionBlob.GetCompRank }}
-`)))
+// The heroes played in comp this session, most played first
+func (sessionData playerSessionData) Heroes() []string {
+	return sortedHeroIds(sessionData.HeroesWDL)
+}
 
 // A BattleTag is 3-12 characters, followed by "#", followed by digits
 var regexBattleTag = regexp.MustCompile(`^\w{3,12}#\d+$`)
 
 func (bot *Bot) getTemplateMessage(template *template.Template, data interface{}) string {
-	var message bytes.Buffer
-	err := template.Execute(&message, data)
+	message, err := executeTemplate(template, data)
 	if err != nil {
 		bot.logger.WithFields(logrus.Fields{
 			"error":    err,
@@ -164,15 +323,18 @@ func (bot *Bot) getTemplateMessage(template *template.Template, data interface{}
 		return ""
 	}
 
-	return message.String()
+	return message
 }
 
 func (bot *Bot) readyHandler(session *discordgo.Session, ready *discordgo.Ready) {
 	//session.UpdateStatus(0, "!help")
 
 	bot.discord.SetGuildAndOverwatchChannel()
+	bot.applyGuildChannel()
 	bot.discord.SetOwnUserId()
+	bot.mutex.Lock()
 	bot.discord.SetPlayerStates(bot.playerStates)
+	bot.mutex.Unlock()
 	bot.setOverwatchStats()
 	//msg, _ := bot.discord.ReadMessage("303409836215762944")
 	//bot.logger.WithField("msg contents", msg.Content).Debug("emoji check")
@@ -180,6 +342,7 @@ func (bot *Bot) readyHandler(session *discordgo.Session, ready *discordgo.Ready)
 
 func (bot *Bot) presenceUpdate(session *discordgo.Session, presenceUpdate *discordgo.PresenceUpdate) {
 	bot.logger.WithField("presenceUpdate", presenceUpdate).WithField("userId", presenceUpdate.User.ID).WithField("game", presenceUpdate.Game).Debug("start handling presenceUpdate")
+	metrics.PresenceUpdates.Inc()
 	if bot.discord.IsStreaming(presenceUpdate.Game) {
 		bot.logger.Info("ignoring streaming update")
 		return
@@ -192,13 +355,26 @@ func (bot *Bot) presenceUpdate(session *discordgo.Session, presenceUpdate *disco
 	if !bot.HasBattleTag(userId) {
 		return
 	}
+	if bot.getPrivacy(userId).OptedOut {
+		return
+	}
+
+	lockedPlayerState, ok := bot.getPlayerState(userId)
+	if !ok {
+		return
+	}
 
-	prevPlayerState := bot.playerStates[userId]
+	lockedPlayerState.UpdateMutex.Lock()
+	defer lockedPlayerState.UpdateMutex.Unlock()
 
-	prevPlayerState.UpdateMutex.Lock()
-	defer prevPlayerState.UpdateMutex.Unlock()
+	// the state may have been replaced or removed by a reload while waiting for the lock
+	prevPlayerState, ok := bot.getPlayerState(userId)
+	if !ok || prevPlayerState.UpdateMutex != lockedPlayerState.UpdateMutex {
+		bot.logger.WithField("userId", userId).Info("abort processing due to changed link")
+		return
+	}
 
-	if prevPlayerState.RecentlyUpdated() {
+	if prevPlayerState.RecentlyUpdated(bot.getConfig().Polling.RecentDuration) {
 		bot.logger.WithField("userId", userId).Info("abort processing due to recent change")
 		return
 	}
@@ -212,102 +388,219 @@ func (bot *Bot) presenceUpdate(session *discordgo.Session, presenceUpdate *disco
 	nextPlayerState.Timestamp = time.Now()
 
 	if startedPlaying(prevPlayerState, nextPlayerState) {
+		metrics.SessionsStarted.Inc()
 		err := bot.setPlayerBlob(&nextPlayerState)
 		if err != nil {
 			return
 		}
+		bot.setAltBlobs(&nextPlayerState)
+		nextPlayerState.StartSnapshots()
 	} else if stoppedPlaying(prevPlayerState, nextPlayerState) {
 		bot.generateSessionReport(&prevPlayerState, &nextPlayerState)
+		nextPlayerState.Snapshots = nil
 	}
 
-	bot.playerStates[userId] = nextPlayerState
+	bot.setPlayerState(userId, nextPlayerState)
 	bot.logger.WithField("prev", prevPlayerState).WithField("next", nextPlayerState).Debug("player state transition")
 }
 
 func (bot *Bot) messageCreate(session *discordgo.Session, messageCreate *discordgo.MessageCreate) {
 	bot.logger.WithField("messageId", messageCreate.ID).WithField("messageChannelId", messageCreate.ChannelID).WithField("messageContent", messageCreate.Content).WithField("messageAuthor", messageCreate.Author).Debug("start handling messageCreate")
-	if bot.discord.GetOverwatchChannelId() != messageCreate.ChannelID {
-		return
-	}
 	if messageCreate.Author.ID == bot.discord.GetOwnUserId() {
 		bot.logger.Info("ignoring own message")
 		return
 	}
 
-	// TODO: these only update the in-memory links
-	// ideally, the change should also be persisted to the input battleTag map file
 	input := strings.SplitN(messageCreate.Content, " ", 2)
+	isDirectMessage := false
+	if bot.discord.GetOverwatchChannelId() != messageCreate.ChannelID {
+		if bot.discord.IsDirectMessageChannel(messageCreate.ChannelID) {
+			isDirectMessage = true
+		} else if input[0] != "!setchannel" {
+			// other channels only accept picking them as the channel
+			return
+		}
+	}
+	if isDirectMessage && guildOnlyCommands[input[0]] {
+		bot.reply(messageCreate.Message, input[0]+" only works in the overwatch channel")
+		return
+	}
 	if input[0] == "!link" && len(input) == 2 {
-		bot.linkPlayerBattleTag(messageCreate.Author, input[1])
+		bot.linkCommand(messageCreate.Message, input[1])
 	} else if input[0] == "!unlink" {
-		bot.unlinkPlayerBattleTag(messageCreate.Author)
+		args := ""
+		if len(input) == 2 {
+			args = input[1]
+		}
+		bot.unlinkCommand(messageCreate.Message, args)
+	} else if input[0] == "!template" && len(input) == 2 {
+		bot.templateCommand(messageCreate.Message, input[1])
+	} else if input[0] == "!emoji" && len(input) == 2 {
+		bot.emojiCommand(messageCreate.Message, input[1])
+	} else if input[0] == "!graph" {
+		args := ""
+		if len(input) == 2 {
+			args = input[1]
+		}
+		bot.graphCommand(messageCreate.Message, args)
+	} else if input[0] == "!season" {
+		args := ""
+		if len(input) == 2 {
+			args = input[1]
+		}
+		bot.seasonCommand(messageCreate.Message, args)
+	} else if input[0] == "!rank" {
+		args := ""
+		if len(input) == 2 {
+			args = input[1]
+		}
+		bot.rankCommand(messageCreate.Message, args)
+	} else if input[0] == "!announce" && len(input) == 2 {
+		bot.announceCommand(messageCreate.Message, input[1])
+	} else if input[0] == "!hero" && len(input) == 2 {
+		bot.heroCommand(messageCreate.Message, input[1])
+	} else if input[0] == "!compare" && len(input) == 2 {
+		bot.compareCommand(messageCreate.Message, input[1])
+	} else if input[0] == "!optout" {
+		bot.optOutCommand(messageCreate.Message)
+	} else if input[0] == "!optin" {
+		bot.optInCommand(messageCreate.Message)
+	} else if input[0] == "!privacy" {
+		args := ""
+		if len(input) == 2 {
+			args = input[1]
+		}
+		bot.privacyCommand(messageCreate.Message, args)
+	} else if input[0] == "!delivery" {
+		args := ""
+		if len(input) == 2 {
+			args = input[1]
+		}
+		bot.deliveryCommand(messageCreate.Message, args)
+	} else if input[0] == "!forgetme" {
+		bot.forgetMeCommand(messageCreate.Message)
+	} else if input[0] == "!stats" {
+		args := ""
+		if len(input) == 2 {
+			args = input[1]
+		}
+		bot.statsCommand(messageCreate.Message, args)
+	} else if input[0] == "!alt" {
+		args := ""
+		if len(input) == 2 {
+			args = input[1]
+		}
+		bot.altCommand(messageCreate.Message, args)
+	} else if input[0] == "!verify" {
+		bot.verifyCommand(messageCreate.Message)
+	} else if input[0] == "!setchannel" {
+		args := ""
+		if len(input) == 2 {
+			args = input[1]
+		}
+		bot.setChannelCommand(messageCreate.Message, args)
+	} else if input[0] == "!config" {
+		bot.configCommand(messageCreate.Message)
+	} else if input[0] == "!reload" {
+		bot.reloadCommand(messageCreate.Message)
 	}
 }
 
-func (bot *Bot) linkPlayerBattleTag(user *discordgo.User, battleTag string) {
-	bot.logger.WithField("user", user).WithField("battleTag", battleTag).Info("link request")
+// Commands that change what the whole guild sees, which are not accepted by
+// direct message
+var guildOnlyCommands = map[string]bool{
+	"!link":       true,
+	"!unlink":     true,
+	"!template":   true,
+	"!emoji":      true,
+	"!announce":   true,
+	"!setchannel": true,
+	"!alt":        true,
+}
 
-	var messageContent string
+// Replies in the channel the message was sent in, which is either the
+// overwatch channel or a direct message channel
+func (bot *Bot) reply(message *discordgo.Message, content string) (*discordgo.Message, error) {
+	return bot.discord.SendMessage(message.ChannelID, content)
+}
 
-	if !regexBattleTag.MatchString(battleTag) {
-		bot.logger.Info("invalid battleTag format")
+func (bot *Bot) replyEmbed(message *discordgo.Message, embed *discordgo.MessageEmbed) (*discordgo.Message, error) {
+	return bot.discord.SendEmbed(message.ChannelID, embed)
+}
 
-		messageContent = battleTag + " is not a valid battleTag"
-		bot.discord.CreateMessage(messageContent)
-		return
-	}
+func (bot *Bot) replyWithFile(message *discordgo.Message, content string, name string, file io.Reader) (*discordgo.Message, error) {
+	return bot.discord.SendMessageWithFile(message.ChannelID, content, name, file)
+}
 
-	ctx, _ := context.WithTimeout(context.Background(), longCommandTimeout)
-	_, err := bot.overwatch.GetUSPlayerBlob(ctx, battleTag)
-	if err != nil {
-		bot.logger.WithError(err).Info("invalid overwatch account")
+func (bot *Bot) linkPlayerBattleTag(user *discordgo.User, battleTag string) {
+	bot.logger.WithField("user", user).WithField("battleTag", battleTag).Info("link request")
 
-		messageContent = battleTag + " is not a valid Overwatch account"
-		bot.discord.CreateMessage(messageContent)
+	if _, ok := bot.getLinkableBlob(battleTag); !ok {
 		return
 	}
 
-	if playerState, ok := bot.playerStates[user.ID]; ok {
+	var messageContent string
+	if playerState, ok := bot.getPlayerState(user.ID); ok {
 		if battleTag == playerState.BattleTag {
 			bot.logger.Info("same link")
 
 			messageContent = user.Username + " is already linked to " + battleTag
-			bot.discord.CreateMessage(messageContent)
 		} else {
 			bot.logger.Info("replacing existing link")
 
-			delete(bot.playerStates, user.ID)
-
 			messageContent = user.Username + "'s existing link to " + playerState.BattleTag + " is updated to " + battleTag
-			bot.discord.CreateMessage(messageContent)
 		}
 	} else {
 		bot.logger.Info("adding new link")
 
 		messageContent = user.Username + " is now linked to " + battleTag
-		bot.discord.CreateMessage(messageContent)
 	}
 
-	playerState := player.New(battleTag)
-	bot.discord.SetUser(user.ID, &playerState)
-	bot.discord.SetPlayerState(user.ID, &playerState)
-	bot.playerStates[user.ID] = playerState
-	bot.setPlayerOverwatchStats(user.ID)
-	bot.logger.WithField("userId", user.ID).WithField("battleTag", battleTag).Debug("added player link")
+	if err := bot.linkPlayer(user.ID, battleTag, store.LinkSourceCommand); err != nil {
+		bot.logger.WithError(err).Error("could not store link")
+		messageContent = "could not link " + user.Username + " to " + battleTag
+	}
+	bot.discord.CreateMessage(messageContent)
+}
+
+// Returns the stats of the battleTag if it is a valid Overwatch account,
+// replying why not if it is not
+func (bot *Bot) getLinkableBlob(battleTag string) (*overwatch.RegionBlob, bool) {
+	if !regexBattleTag.MatchString(battleTag) {
+		bot.logger.Info("invalid battleTag format")
+		bot.discord.CreateMessage(battleTag + " is not a valid battleTag")
+		return nil, false
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), bot.getConfig().Polling.LongCommandTimeout)
+	defer cancel()
+	regionBlob, err := bot.overwatch.GetUSPlayerBlob(ctx, battleTag)
+	if err != nil {
+		bot.logger.WithError(err).Info("invalid overwatch account")
+		bot.discord.CreateMessage(battleTag + " is not a valid Overwatch account")
+		return nil, false
+	}
+
+	return regionBlob, true
 }
 
 func (bot *Bot) unlinkPlayerBattleTag(user *discordgo.User) {
 	bot.logger.WithField("user", user).Info("unlink request")
 
-	battleTag := bot.playerStates[user.ID].BattleTag
-	delete(bot.playerStates, user.ID)
+	playerState, _ := bot.getPlayerState(user.ID)
+	battleTag := playerState.BattleTag
+	if err := bot.unlinkPlayer(user.ID); err != nil {
+		bot.logger.WithError(err).Error("could not remove link")
+		bot.discord.CreateMessage("could not unlink " + user.Username)
+		return
+	}
 
 	messageContent := user.Username + " unlinked from " + battleTag
 	bot.discord.CreateMessage(messageContent)
 }
 
 func (bot *Bot) setOverwatchStats() {
-	for userId, playerState := range bot.playerStates {
+	for userId, playerState := range bot.getPlayerStates() {
 		if playerState.BattleTag == "" {
 			bot.logger.WithField("userId", userId).Warn("can't get player stats without a battleTag")
 			continue
@@ -318,7 +611,7 @@ func (bot *Bot) setOverwatchStats() {
 }
 
 func (bot *Bot) setPlayerOverwatchStats(userId string) {
-	playerState, ok := bot.playerStates[userId]
+	playerState, ok := bot.getPlayerState(userId)
 	if !ok {
 		return
 	}
@@ -326,7 +619,9 @@ func (bot *Bot) setPlayerOverwatchStats(userId string) {
 	if bot.discord.IsOverwatch(playerState.Game) {
 		bot.logger.WithField("userId", userId).Debug("initializing player overwatch stats")
 		bot.setPlayerBlob(&playerState)
-		bot.playerStates[userId] = playerState
+		bot.setAltBlobs(&playerState)
+		playerState.StartSnapshots()
+		bot.setPlayerState(userId, playerState)
 	}
 }
 
@@ -336,43 +631,213 @@ func (bot *Bot) generateSessionReport(prev *player.PlayerState, next *player.Pla
 		return
 	}
 
+	privacy := bot.getPrivacy(prev.User.ID)
+	if privacy.OptedOut {
+		bot.logger.WithField("userId", prev.User.ID).Info("skipping session report of opted out player")
+		return
+	}
+
+	bot.addPendingReport(prev)
+	defer bot.removePendingReport(prev.User.ID)
+
 	// unfortunately, owapi only updates after a player has closed overwatch,
 	// and sometimes it takes several minutes before changes are visible
 	bot.logger.WithField("player", prev.User.Username).Debug("attempt to get user stats")
-	for i := 0; i < maxGetUserStatsAttempts; i++ {
+	for i := 0; i < bot.getConfig().Polling.MaxGetUserStatsAttempts; i++ {
 		bot.logger.WithField("attempt", i).Debug("retry")
+		if i > 0 {
+			metrics.StatsRetries.Inc()
+		}
+		bot.setPendingReportAttempt(prev.User.ID, i+1)
 
 		bot.setPlayerBlob(next)
+		bot.setAltBlobs(next)
 
-		if !prev.RegionBlob.Equals(next.RegionBlob) {
+		if !prev.RegionBlob.Equals(next.RegionBlob) || len(getChangedAlts(prev, next)) > 0 {
 			bot.logger.Debug("successfully retrieved updated stats")
 			break
 		}
 
-		time.Sleep(1 * time.Minute)
+		time.Sleep(bot.getConfig().Polling.RetryInterval)
 	}
 
+	// every account whose stats changed is reported, and the primary account
+	// if none did
+	changedAlts := getChangedAlts(prev, next)
+	if len(changedAlts) == 0 || hasChangedStats(prev, next) {
+		bot.reportSession(prev, next, privacy)
+	}
+	for _, i := range changedAlts {
+		prevAlt, nextAlt := prev.ForAlt(i), next.ForAlt(i)
+		bot.reportSession(&prevAlt, &nextAlt, privacy)
+	}
+}
+
+// Stores and posts the report of the session of one account
+func (bot *Bot) reportSession(prev *player.PlayerState, next *player.PlayerState, privacy store.Privacy) {
+	var record *store.SessionRecord
+	if hasChangedStats(prev, next) {
+		record = bot.storeSession(prev.User.ID, bot.getPlayerSessionData(prev, next), prev.Timestamp, next.Timestamp)
+	}
+
+	messageContent := bot.getSessionReportMessage(prev, next, privacy)
+	if messageContent == "" {
+		return
+	}
+	bot.postSessionReport(prev.User.ID, messageContent, record != nil, privacy)
+
+	if record != nil {
+		bot.announceMilestones(prev, next, *record, privacy)
+	}
+}
+
+// Posts a session report as the player chose, with a sparkline if enabled,
+// the session was stored and the report is public with SR
+func (bot *Bot) postSessionReport(userId string, messageContent string, stored bool, privacy store.Privacy) {
+	reported := false
+	if privacy.SendsToChannel() {
+		var sparkline *bytes.Buffer
+		if stored && privacy.Mode == store.PrivacyPublic && bot.getConfig().Guild.ReportSparkline {
+			sparkline = bot.getSparkline(userId)
+		}
+
+		var err error
+		if sparkline != nil {
+			_, err = bot.discord.CreateMessageWithFile(messageContent, "sparkline.png", sparkline)
+		} else {
+			_, err = bot.discord.CreateMessage(messageContent)
+		}
+		if err != nil {
+			bot.logger.WithError(err).WithField("userId", userId).Error("could not post session report")
+		} else {
+			reported = true
+		}
+	}
+	if privacy.SendsDM() {
+		if _, err := bot.discord.CreateDirectMessage(userId, messageContent); err != nil {
+			bot.logger.WithError(err).WithField("userId", userId).Error("could not send session report")
+		} else {
+			reported = true
+		}
+	}
+
+	if reported {
+		metrics.SessionsReported.Inc()
+	}
+}
+
+// The changes between two player states that both have stats. If a new
+// season started in between, the comp stats of the old season are ignored,
+// as the new season's stats start from zero.
+func (bot *Bot) getPlayerSessionData(prev *player.PlayerState, next *player.PlayerState) playerSessionData {
+	hours, minutes := getHoursMinutesFromDuration(next.Timestamp.Sub(prev.Timestamp))
+	sessionData := playerSessionData{
+		Username:     next.User.Username,
+		BattleTag:    next.BattleTag,
+		InitialSR:    prev.RegionBlob.GetCompRank(),
+		FinalSR:      next.RegionBlob.GetCompRank(),
+		Hours:        hours,
+		Minutes:      minutes,
+		QuickplayWDL: overwatch.GetQuickplayWDLDiff(prev.RegionBlob, next.RegionBlob),
+		NewSeason:    overwatch.IsNewSeason(prev.RegionBlob, next.RegionBlob),
+		HeroEmojis:   bot.getHeroEmojis(),
+		TierEmojis:   bot.getTierEmojis(),
+	}
+
+	prevHeroStats := prev.RegionBlob.GetAllHeroStats()
+	if sessionData.NewSeason || prevHeroStats == nil {
+		prevHeroStats = &overwatch.AllHeroStats{}
+	}
+	if sessionData.NewSeason {
+		sessionData.InitialSR = 0
+	}
+	sessionData.HeroesWDL = bot.getHeroesWDL(prevHeroStats, next.RegionBlob.GetAllHeroStats())
+	sessionData.QuickplayHeroesWDL = getQuickplayHeroesWDL(prev.RegionBlob.GetQuickplayHeroStats(), next.RegionBlob.GetQuickplayHeroStats())
+	sessionData.Performance, _ = overwatch.GetCompGameStatsDiff(prev.RegionBlob, next.RegionBlob)
+	sessionData.Matches = overwatch.InferMatches(getSessionSnapshots(prev, next))
+	if len(next.Alts) > 0 {
+		sessionData.Account = next.BattleTag
+		sessionData.Alt = next.IsAlt
+	}
+
+	// there is no SR change to report going into or out of placements
+	if sessionData.InitialSR != 0 && sessionData.FinalSR != 0 {
+		sessionData.SRDiff = sessionData.FinalSR - sessionData.InitialSR
+	}
+
+	return sessionData
+}
+
+// The snapshots taken during the session, ending with the stats of next
+func getSessionSnapshots(prev *player.PlayerState, next *player.PlayerState) []overwatch.Snapshot {
+	snapshots := prev.Snapshots
+	if len(snapshots) == 0 || snapshots[0].RegionBlob != prev.RegionBlob {
+		snapshots = []overwatch.Snapshot{{Time: prev.Timestamp, RegionBlob: prev.RegionBlob}}
+	}
+
+	last := snapshots[len(snapshots)-1]
+	if last.RegionBlob.Equals(next.RegionBlob) {
+		return snapshots
+	}
+	return append(snapshots[:len(snapshots):len(snapshots)], overwatch.Snapshot{Time: next.Timestamp, RegionBlob: next.RegionBlob})
+}
+
+// Stores the session, returning the stored record or nil if it could not
+// be stored
+func (bot *Bot) storeSession(userId string, sessionData playerSessionData, start time.Time, end time.Time) *store.SessionRecord {
+	bot.seasonMutex.Lock()
+	defer bot.seasonMutex.Unlock()
+
+	record := store.SessionRecord{
+		UserId:             userId,
+		BattleTag:          sessionData.BattleTag,
+		Start:              start,
+		End:                end,
+		InitialSR:          sessionData.InitialSR,
+		FinalSR:            sessionData.FinalSR,
+		HeroesWDL:          sessionData.HeroesWDL,
+		QuickplayWDL:       sessionData.QuickplayWDL,
+		QuickplayHeroesWDL: sessionData.QuickplayHeroesWDL,
+		Season:             bot.getSessionSeason(userId, start, sessionData.NewSeason),
+		Placement:          sessionData.IsPlacement(),
+		Matches:            sessionData.Matches,
+		Alt:                sessionData.Alt,
+	}
+	if sessionData.HasPerformance() {
+		performance := sessionData.Performance
+		record.Performance = &performance
+	}
+
+	if err := bot.store.PutSession(record); err != nil {
+		bot.logger.WithError(err).WithField("userId", userId).Error("could not store session")
+		return nil
+	}
+	bot.advanceSeason(record.Season, start)
+
+	return &record
+}
+
+// Renders the report of the session between the prev and next states, or
+// an empty string if there is nothing to report. Reports of players hiding
+// their SR leave it out, and are not made if they would only show the SR.
+func (bot *Bot) getSessionReportMessage(prev *player.PlayerState, next *player.PlayerState, privacy store.Privacy) string {
 	var messageContent string
 	if prev.RegionBlob == nil && next.RegionBlob == nil {
 		bot.logger.Warn("no user stats found")
+	} else if (prev.RegionBlob == nil || next.RegionBlob == nil) && privacy.HideSR() {
+		bot.logger.Info("no session stats to report without SR")
 	} else if prev.RegionBlob == nil && next.RegionBlob != nil {
 		bot.logger.Warn("no previous user stats found")
-		messageContent = bot.getTemplateMessage(templateNoChangeMessage, next)
+		messageContent = bot.getTemplateMessage(bot.getTemplate(noChangeTemplateName), bot.getNoChangeSessionData(next))
 	} else if prev.RegionBlob != nil && next.RegionBlob == nil {
 		bot.logger.Warn("no next user stats found")
-		messageContent = bot.getTemplateMessage(templateNoChangeMessage, prev)
+		messageContent = bot.getTemplateMessage(bot.getTemplate(noChangeTemplateName), bot.getNoChangeSessionData(prev))
 	} else if !prev.RegionBlob.Equals(next.RegionBlob) {
-		hours, minutes := getHoursMinutesFromDuration(next.Timestamp.Sub(prev.Timestamp))
-		playerSessionData := playerSessionData{
-			Username:     next.User.Username,
-			FinalSR:      next.RegionBlob.GetCompRank(),
-			SRDiff:       next.RegionBlob.GetCompRank() - prev.RegionBlob.GetCompRank(),
-			Hours:        hours,
-			Minutes:      minutes,
-			HeroesWDL:    bot.getHeroesWDL(prev.RegionBlob.GetAllHeroStats(), next.RegionBlob.GetAllHeroStats()),
-			QuickplayWDL: overwatch.GetQuickplayWDLDiff(prev.RegionBlob, next.RegionBlob),
-		}
-		messageContent = bot.getTemplateMessage(templateDiffMessage, playerSessionData)
+		playerSessionData := bot.getPlayerSessionData(prev, next)
+		if privacy.HideSR() {
+			playerSessionData = withoutSR(playerSessionData)
+		}
+		messageContent = bot.getTemplateMessage(bot.getTemplate(sessionTemplateName), playerSessionData)
 
 		bot.logger.WithField("playerSessionData", playerSessionData).Info("outputting session data")
 	} else {
@@ -380,13 +845,69 @@ func (bot *Bot) generateSessionReport(prev *player.PlayerState, next *player.Pla
 		bot.logger.Info("session ended with no change")
 	}
 
-	if messageContent != "" {
-		bot.discord.CreateMessage(messageContent)
+	return messageContent
+}
+
+// Session data for a player whose stats are only known at one point in time
+func (bot *Bot) getNoChangeSessionData(playerState *player.PlayerState) playerSessionData {
+	sessionData := playerSessionData{
+		Username:   playerState.User.Username,
+		BattleTag:  playerState.BattleTag,
+		InitialSR:  playerState.RegionBlob.GetCompRank(),
+		FinalSR:    playerState.RegionBlob.GetCompRank(),
+		TierEmojis: bot.getTierEmojis(),
+	}
+	if len(playerState.Alts) > 0 {
+		sessionData.Account = playerState.BattleTag
+		sessionData.Alt = playerState.IsAlt
 	}
+	return sessionData
+}
+
+func hasChangedStats(prev *player.PlayerState, next *player.PlayerState) bool {
+	return prev.RegionBlob != nil && next.RegionBlob != nil && !prev.RegionBlob.Equals(next.RegionBlob)
+}
+
+// Returns the indexes of the alt accounts whose stats changed
+func getChangedAlts(prev *player.PlayerState, next *player.PlayerState) []int {
+	var changed []int
+	for i := range prev.Alts {
+		if i >= len(next.Alts) || prev.Alts[i].BattleTag != next.Alts[i].BattleTag {
+			break
+		}
+		prevAlt, nextAlt := prev.ForAlt(i), next.ForAlt(i)
+		if hasChangedStats(&prevAlt, &nextAlt) {
+			changed = append(changed, i)
+		}
+	}
+	return changed
+}
+
+// Fetches the stats of the player's alt accounts. Accounts whose stats
+// cannot be fetched are left without stats.
+func (bot *Bot) setAltBlobs(playerState *player.PlayerState) {
+	if len(playerState.Alts) == 0 {
+		return
+	}
+
+	// the alts may be shared with a copy of the state
+	alts := make([]player.Account, len(playerState.Alts))
+	for i, alt := range playerState.Alts {
+		ctx, cancel := context.WithTimeout(context.Background(), bot.getConfig().Polling.CommandTimeout)
+		blob, err := bot.overwatch.GetUSPlayerBlob(ctx, alt.BattleTag)
+		cancel()
+		if err != nil {
+			bot.logger.WithError(err).WithField("battleTag", alt.BattleTag).Error("failed to get alt blob data")
+		}
+
+		alts[i] = player.Account{BattleTag: alt.BattleTag, RegionBlob: blob}
+	}
+	playerState.Alts = alts
 }
 
 func (bot *Bot) setPlayerBlob(playerState *player.PlayerState) error {
-	ctx, _ := context.WithTimeout(context.Background(), commandTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), bot.getConfig().Polling.CommandTimeout)
+	defer cancel()
 
 	blob, err := bot.overwatch.GetUSPlayerBlob(ctx, playerState.BattleTag)
 	if err != nil {
@@ -399,6 +920,29 @@ func (bot *Bot) setPlayerBlob(playerState *player.PlayerState) error {
 	return nil
 }
 
+// The quickplay results of each hero played between the stats. Quickplay
+// stats carry over between seasons.
+func getQuickplayHeroesWDL(prev *overwatch.AllHeroStats, next *overwatch.AllHeroStats) map[string]overwatch.WDL {
+	heroesWDL := make(map[string]overwatch.WDL)
+
+	for _, hero := range overwatch.Heroes {
+		nextHero := next.HeroStats(hero.Id)
+		if nextHero == nil {
+			continue
+		}
+		prevHero := prev.HeroStats(hero.Id)
+		if prevHero == nil {
+			prevHero = &overwatch.HeroStruct{}
+		}
+
+		if wdl := overwatch.MakeQuickplayWDL(prevHero, nextHero); !wdl.IsEmpty() {
+			heroesWDL[hero.Id] = wdl
+		}
+	}
+
+	return heroesWDL
+}
+
 func (bot *Bot) getHeroesWDL(prev *overwatch.AllHeroStats, next *overwatch.AllHeroStats) map[string]overwatch.WDL {
 	heroesWDL := make(map[string]overwatch.WDL)
 
@@ -607,3 +1151,9 @@ func getHoursMinutesFromDuration(duration time.Duration) (int, int) {
 
 	return hours, minutes
 }
+
+// Formats a duration like "1h 05m"
+func formatHoursMinutes(duration time.Duration) string {
+	hours, minutes := getHoursMinutesFromDuration(duration)
+	return fmt.Sprintf("%dh %02dm", hours, minutes)
+}
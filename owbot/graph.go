@@ -0,0 +1,128 @@
+package owbot
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/snakelayer/discord-oversessions/owbot/chart"
+	"github.com/snakelayer/discord-oversessions/owbot/store"
+)
+
+const (
+	defaultGraphDays = 30
+	maxGraphDays     = 365
+
+	// Days of sessions shown in the sparkline attached to session reports
+	sparklineDays = 14
+)
+
+// A user mention as written in a message
+var regexUserMention = regexp.MustCompile(`^<@!?(\d+)>$`)
+
+// A number of days, eg. "30d"
+var regexDays = regexp.MustCompile(`^(\d+)d$`)
+
+const graphUsage = "usage: `!graph [@user] [days, eg. 30d]`"
+
+// Handles the !graph command, posting a chart of the SR of the author or
+// the mentioned user over the last days.
+func (bot *Bot) graphCommand(message *discordgo.Message, args string) {
+	bot.logger.WithField("user", message.Author).WithField("args", args).Info("graph request")
+
+	userId := message.Author.ID
+	days := defaultGraphDays
+	for _, arg := range strings.Fields(args) {
+		if match := regexUserMention.FindStringSubmatch(arg); match != nil {
+			userId = match[1]
+		} else if match := regexDays.FindStringSubmatch(arg); match != nil {
+			days, _ = strconv.Atoi(match[1])
+			if days < 1 || days > maxGraphDays {
+				bot.reply(message, fmt.Sprintf("days must be between 1 and %d", maxGraphDays))
+				return
+			}
+		} else {
+			bot.reply(message, graphUsage)
+			return
+		}
+	}
+
+	name, ok := bot.getPlayerNames()[userId]
+	if !ok {
+		bot.reply(message, "that user is not linked to a battleTag")
+		return
+	}
+	if !bot.canShowPlayer(message, userId, true) {
+		return
+	}
+
+	records, err := bot.store.GetSessions(userId, time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		bot.logger.WithError(err).WithField("userId", userId).Error("could not get sessions")
+		bot.reply(message, "could not get sessions")
+		return
+	}
+
+	var image bytes.Buffer
+	if err := chart.RenderSRChart(&image, chartSessions(records)); err == chart.ErrNoSessions {
+		bot.reply(message, fmt.Sprintf("**%s** has no competitive sessions in the last %d days", name, days))
+		return
+	} else if err != nil {
+		bot.logger.WithError(err).Error("could not render SR chart")
+		return
+	}
+
+	content := fmt.Sprintf("**%s**: SR over the last %d days", name, days)
+	if _, err := bot.replyWithFile(message, content, "sr.png", &image); err != nil {
+		bot.logger.WithError(err).Error("could not send SR chart")
+	}
+}
+
+// Renders a sparkline of the user's recent sessions in the current season,
+// or returns nil if there are none
+func (bot *Bot) getSparkline(userId string) *bytes.Buffer {
+	records, err := bot.store.GetSessions(userId, time.Now().AddDate(0, 0, -sparklineDays))
+	if err != nil {
+		bot.logger.WithError(err).WithField("userId", userId).Error("could not get sessions")
+		return nil
+	}
+	for len(records) > 0 && records[0].Season != records[len(records)-1].Season {
+		records = records[1:]
+	}
+
+	var image bytes.Buffer
+	if err := chart.RenderSparkline(&image, chartSessions(records)); err != nil {
+		if err != chart.ErrNoSessions {
+			bot.logger.WithError(err).Error("could not render sparkline")
+		}
+		return nil
+	}
+
+	return &image
+}
+
+func chartSessions(records []store.SessionRecord) []chart.Session {
+	sessions := make([]chart.Session, 0, len(records))
+	for _, record := range records {
+		// the SR of alt accounts would mix with the SR of the primary account
+		if record.Alt {
+			continue
+		}
+		compWDL := record.CompWDL()
+		sessions = append(sessions, chart.Session{
+			Start:     record.Start,
+			End:       record.End,
+			InitialSR: record.InitialSR,
+			FinalSR:   record.FinalSR,
+			Wins:      compWDL.Win,
+			Losses:    compWDL.Loss,
+			Season:    record.Season,
+		})
+	}
+
+	return sessions
+}
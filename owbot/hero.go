@@ -0,0 +1,145 @@
+package owbot
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/snakelayer/discord-oversessions/owbot/overwatch"
+)
+
+const (
+	heroUsage = "usage: `!hero <name> [@user]`"
+
+	// Days of sessions summed up as the recent results of a hero
+	recentHeroDays = 30
+)
+
+// Handles the !hero command, showing the career stats of the author or the
+// mentioned user on a hero, and their results on it in recent sessions
+func (bot *Bot) heroCommand(message *discordgo.Message, args string) {
+	bot.logger.WithField("user", message.Author).WithField("args", args).Info("hero request")
+
+	userId := message.Author.ID
+	input := strings.Fields(args)
+	if len(input) > 1 {
+		if match := regexUserMention.FindStringSubmatch(input[len(input)-1]); match != nil {
+			userId = match[1]
+			input = input[:len(input)-1]
+		}
+	}
+	if len(input) == 0 {
+		bot.reply(message, heroUsage)
+		return
+	}
+
+	hero, ok := overwatch.FindHero(strings.Join(input, " "))
+	if !ok {
+		bot.reply(message, strings.Join(input, " ")+" is not a hero")
+		return
+	}
+
+	playerState, ok := bot.getPlayerState(userId)
+	if !ok {
+		bot.reply(message, "that user is not linked to a battleTag")
+		return
+	}
+	if !bot.canShowPlayer(message, userId, false) {
+		return
+	}
+	if playerState.RegionBlob == nil {
+		bot.reply(message, "no stats known for "+playerState.BattleTag+" yet")
+		return
+	}
+
+	records, err := bot.store.GetSessions(userId, time.Now().AddDate(0, 0, -recentHeroDays))
+	if err != nil {
+		bot.logger.WithError(err).WithField("userId", userId).Error("could not get sessions")
+		records = nil
+	}
+
+	var buffer bytes.Buffer
+	fmt.Fprintf(&buffer, "**%s** on %s", bot.getPlayerNames()[userId], hero.Name)
+
+	compStats := playerState.RegionBlob.GetAllHeroStats().HeroStats(hero.Id)
+	quickplayStats := playerState.RegionBlob.GetQuickplayHeroStats().HeroStats(hero.Id)
+	if compStats == nil && quickplayStats == nil {
+		buffer.WriteString("\nnot played yet")
+	}
+	if compStats != nil {
+		general := compStats.GeneralStats
+		fmt.Fprintf(&buffer, "\ncomp this season: %s, %.0f W / %.0f L", formatHeroTimePlayed(general.TimePlayed), general.GamesWon, general.GamesLost)
+		if general.GamesPlayed > 0 {
+			fmt.Fprintf(&buffer, " (%.0f%% won)", general.GamesWon*100/general.GamesPlayed)
+			fmt.Fprintf(&buffer, ", %.1f elims and %.1f deaths per game", general.Eliminations/general.GamesPlayed, general.Deaths/general.GamesPlayed)
+		}
+		writeHeroStats(&buffer, "comp averages", compStats.AverageStats)
+		writeHeroStats(&buffer, "comp hero stats", compStats.HeroStats)
+	}
+	if quickplayStats != nil {
+		fmt.Fprintf(&buffer, "\nquickplay career: %s, %.0f wins", formatHeroTimePlayed(quickplayStats.GeneralStats.TimePlayed), quickplayStats.GeneralStats.GamesWon)
+	}
+
+	var compWDL, quickplayWDL overwatch.WDL
+	sessions := 0
+	for _, record := range records {
+		comp, quickplay := record.HeroesWDL[hero.Id], record.QuickplayHeroesWDL[hero.Id]
+		if comp.IsEmpty() && quickplay.IsEmpty() {
+			continue
+		}
+		sessions++
+		compWDL.Win += comp.Win
+		compWDL.Draw += comp.Draw
+		compWDL.Loss += comp.Loss
+		quickplayWDL.Win += quickplay.Win
+		quickplayWDL.Loss += quickplay.Loss
+	}
+	if sessions > 0 {
+		fmt.Fprintf(&buffer, "\nlast %d days: %d %s, comp %d W / %d D / %d L, quickplay %d %s",
+			recentHeroDays, sessions, plural(sessions, "session", "sessions"),
+			compWDL.Win, compWDL.Draw, compWDL.Loss, quickplayWDL.Win, plural(quickplayWDL.Win, "win", "wins"))
+	} else {
+		fmt.Fprintf(&buffer, "\nnot played in a session in the last %d days", recentHeroDays)
+	}
+
+	bot.reply(message, buffer.String())
+}
+
+// Writes the stats sorted by name, eg. "healing_done_average" as
+// "healing done 8123"
+func writeHeroStats(buffer *bytes.Buffer, title string, stats map[string]float32) {
+	if len(stats) == 0 {
+		return
+	}
+
+	var names []string
+	for name := range stats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintf(buffer, "\n%s:", title)
+	for i, name := range names {
+		if i > 0 {
+			buffer.WriteString(",")
+		}
+		label := strings.Replace(strings.TrimSuffix(name, "_average"), "_", " ", -1)
+		fmt.Fprintf(buffer, " %s %s", label, formatHeroStat(stats[name]))
+	}
+}
+
+// formatHeroStat 12 => "12", formatHeroStat 0.456 => "0.46"
+func formatHeroStat(value float32) string {
+	if value == float32(int(value)) {
+		return fmt.Sprintf("%d", int(value))
+	}
+	return fmt.Sprintf("%.2f", value)
+}
+
+// Formats the time played on a hero, which owapi reports in hours
+func formatHeroTimePlayed(hours float32) string {
+	return formatHoursMinutes(time.Duration(hours * float32(time.Hour)))
+}
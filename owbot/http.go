@@ -0,0 +1,54 @@
+package owbot
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/snakelayer/discord-oversessions/owbot/config"
+)
+
+// Starts serving /metrics and /healthz on the configured address, along with
+// the admin api and the dashboard if they are enabled
+func (bot *Bot) startHTTPServer(httpConfig config.HTTPConfig) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/healthz", bot.healthzHandler)
+	if httpConfig.AdminToken != "" {
+		bot.registerAPIHandlers(mux, httpConfig.AdminToken)
+	}
+	if httpConfig.Dashboard {
+		bot.registerDashboardHandlers(mux)
+	}
+
+	bot.httpServer = &http.Server{Addr: httpConfig.Listen, Handler: mux}
+	go func() {
+		bot.logger.WithField("listen", httpConfig.Listen).Info("http server starting")
+		if err := bot.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			bot.logger.WithError(err).Error("http server failed")
+		}
+	}()
+}
+
+func (bot *Bot) stopHTTPServer() {
+	if bot.httpServer == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := bot.httpServer.Shutdown(ctx); err != nil {
+		bot.logger.WithError(err).Error("could not stop http server")
+	}
+}
+
+// Responds 200 while connected to Discord, and 503 otherwise
+func (bot *Bot) healthzHandler(w http.ResponseWriter, r *http.Request) {
+	if !bot.discord.IsConnected() {
+		http.Error(w, "disconnected from discord", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Write([]byte("ok\n"))
+}
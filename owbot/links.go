@@ -0,0 +1,219 @@
+package owbot
+
+import (
+	"errors"
+
+	"github.com/snakelayer/discord-oversessions/owbot/overwatch"
+	"github.com/snakelayer/discord-oversessions/owbot/player"
+	"github.com/snakelayer/discord-oversessions/owbot/store"
+)
+
+// Links the user to battleTag as their primary account, storing the link.
+// A linked player is retagged, keeping any session in progress and their
+// alt accounts.
+func (bot *Bot) linkPlayer(userId string, battleTag string, source string) error {
+	link := store.Link{UserId: userId, BattleTag: battleTag, Source: source}
+	if prevLink, err := bot.store.GetLink(userId); err != nil {
+		return err
+	} else if prevLink != nil {
+		link.Alts = removeBattleTag(prevLink.Alts, battleTag)
+	}
+	if err := bot.store.PutLink(link); err != nil {
+		return err
+	}
+
+	if playerState, ok := bot.getPlayerState(userId); !ok {
+		bot.addPlayer(userId, battleTag)
+	} else if playerState.BattleTag != battleTag {
+		bot.retagPlayer(userId, battleTag)
+	}
+	return nil
+}
+
+// Stores the primary and alt accounts of a linked user and applies them to
+// the player, keeping the stats already known of each account. Alt accounts
+// added during a session are reported from the next session on.
+func (bot *Bot) setPlayerAccounts(userId string, battleTag string, alts []string) error {
+	link, err := bot.store.GetLink(userId)
+	if err != nil {
+		return err
+	}
+	if link == nil {
+		return errors.New("user is not linked")
+	}
+	link.BattleTag = battleTag
+	link.Alts = alts
+	if err := bot.store.PutLink(*link); err != nil {
+		return err
+	}
+
+	lockedPlayerState, ok := bot.getPlayerState(userId)
+	if !ok {
+		return nil
+	}
+
+	lockedPlayerState.UpdateMutex.Lock()
+	defer lockedPlayerState.UpdateMutex.Unlock()
+
+	playerState, ok := bot.getPlayerState(userId)
+	if !ok {
+		return nil
+	}
+
+	blobs := map[string]*overwatch.RegionBlob{playerState.BattleTag: playerState.RegionBlob}
+	for _, alt := range playerState.Alts {
+		blobs[alt.BattleTag] = alt.RegionBlob
+	}
+
+	prevBattleTag := playerState.BattleTag
+	playerState.SetAlts(alts)
+	for i := range playerState.Alts {
+		playerState.Alts[i].RegionBlob = blobs[playerState.Alts[i].BattleTag]
+	}
+	if battleTag != prevBattleTag {
+		// snapshots are only taken of the primary account
+		playerState.BattleTag = battleTag
+		playerState.RegionBlob = blobs[battleTag]
+		playerState.Snapshots = nil
+		if bot.discord.IsOverwatch(playerState.Game) {
+			if playerState.RegionBlob == nil {
+				bot.setPlayerBlob(&playerState)
+			}
+			playerState.StartSnapshots()
+		}
+	}
+
+	bot.setPlayerState(userId, playerState)
+	bot.logger.WithField("userId", userId).WithField("battleTag", battleTag).WithField("alts", alts).Info("set player accounts")
+	return nil
+}
+
+// Returns the battleTags without battleTag
+func removeBattleTag(battleTags []string, battleTag string) []string {
+	var rest []string
+	for _, other := range battleTags {
+		if other != battleTag {
+			rest = append(rest, other)
+		}
+	}
+	return rest
+}
+
+func (bot *Bot) unlinkPlayer(userId string) error {
+	if err := bot.store.DeleteLink(userId); err != nil {
+		return err
+	}
+
+	bot.removePlayer(userId)
+	return nil
+}
+
+// Updates the stored links from the battleTag file to match battleTagMap.
+// Returns the users whose battleTag changed (including new users), and the
+// users whose link was removed.
+func syncFileLinks(linkStore *store.Store, battleTagMap map[string]string) (map[string]string, []string, error) {
+	links, err := linkStore.GetLinks()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var removed []string
+	for userId, link := range links {
+		if _, ok := battleTagMap[userId]; ok || link.Source != store.LinkSourceFile {
+			continue
+		}
+
+		if err := linkStore.DeleteLink(userId); err != nil {
+			return nil, nil, err
+		}
+		removed = append(removed, userId)
+	}
+
+	changed := make(map[string]string)
+	for userId, battleTag := range battleTagMap {
+		link, ok := links[userId]
+		if ok && link.BattleTag == battleTag && link.Source == store.LinkSourceFile {
+			continue
+		}
+
+		link.UserId = userId
+		link.BattleTag = battleTag
+		link.Alts = removeBattleTag(link.Alts, battleTag)
+		link.Source = store.LinkSourceFile
+		if err := linkStore.PutLink(link); err != nil {
+			return nil, nil, err
+		}
+		if !ok || links[userId].BattleTag != battleTag {
+			changed[userId] = battleTag
+		}
+	}
+
+	return changed, removed, nil
+}
+
+func (bot *Bot) addPlayer(userId string, battleTag string) {
+	playerState := player.New(battleTag)
+	if link, err := bot.store.GetLink(userId); err != nil {
+		bot.logger.WithError(err).WithField("userId", userId).Error("could not get link")
+	} else if link != nil {
+		playerState.SetAlts(link.Alts)
+	}
+	if bot.discord.SetUser(userId, &playerState) == nil && playerState.User.Bot {
+		return
+	}
+	bot.discord.SetPlayerState(userId, &playerState)
+
+	bot.setPlayerState(userId, playerState)
+	bot.setPlayerOverwatchStats(userId)
+	bot.logger.WithField("userId", userId).WithField("battleTag", battleTag).Info("added player")
+}
+
+// Makes battleTag the primary account of the player, dropping it from the
+// alt accounts like linkPlayer and syncFileLinks do for the stored link, so
+// that it is not reported twice
+func (bot *Bot) retagPlayer(userId string, battleTag string) {
+	lockedPlayerState, ok := bot.getPlayerState(userId)
+	if !ok {
+		return
+	}
+
+	lockedPlayerState.UpdateMutex.Lock()
+	defer lockedPlayerState.UpdateMutex.Unlock()
+
+	playerState, ok := bot.getPlayerState(userId)
+	if !ok {
+		return
+	}
+
+	var alts []player.Account
+	for _, alt := range playerState.Alts {
+		if alt.BattleTag != battleTag {
+			alts = append(alts, alt)
+		}
+	}
+
+	prevBattleTag := playerState.BattleTag
+	playerState.BattleTag = battleTag
+	playerState.RegionBlob = nil
+	playerState.Alts = alts
+	if bot.discord.IsOverwatch(playerState.Game) {
+		bot.setPlayerBlob(&playerState)
+	}
+
+	bot.setPlayerState(userId, playerState)
+	bot.logger.WithField("userId", userId).WithField("prevBattleTag", prevBattleTag).WithField("battleTag", battleTag).Info("retagged player")
+}
+
+// Removes the player, after any in-flight presence update or session report
+// of the player finished
+func (bot *Bot) removePlayer(userId string) {
+	playerState, ok := bot.getPlayerState(userId)
+	if !ok {
+		return
+	}
+
+	playerState.UpdateMutex.Lock()
+	bot.deletePlayerState(userId)
+	playerState.UpdateMutex.Unlock()
+	bot.logger.WithField("userId", userId).WithField("battleTag", playerState.BattleTag).Info("removed player")
+}
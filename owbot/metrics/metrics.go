@@ -0,0 +1,86 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "oversessions"
+
+var (
+	DiscordConnected = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "discord_connected",
+		Help:      "1 if the bot is connected to the Discord gateway, 0 otherwise.",
+	})
+
+	PresenceUpdates = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "presence_updates_total",
+		Help:      "Number of presence updates processed.",
+	})
+
+	SessionsStarted = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "sessions_started_total",
+		Help:      "Number of Overwatch sessions started by linked players.",
+	})
+
+	SessionsReported = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "sessions_reported_total",
+		Help:      "Number of session reports posted.",
+	})
+
+	PendingReports = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "pending_reports",
+		Help:      "Number of session reports waiting for updated stats.",
+	})
+
+	StatsRetries = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "stats_retries_total",
+		Help:      "Number of times stats were fetched again because they had not updated yet.",
+	})
+
+	SessionSnapshots = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "session_snapshots_total",
+		Help:      "Number of changed stats fetched while players were in a session.",
+	})
+
+	LinkedPlayers = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "linked_players",
+		Help:      "Number of Discord users linked to a battleTag.",
+	})
+
+	OverwatchRequestDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "overwatch_request_duration_seconds",
+		Help:      "Latency of requests to the Overwatch stats api.",
+		Buckets:   []float64{0.25, 0.5, 1, 2, 5, 10, 20, 30},
+	})
+
+	// Labeled by http status code, or "error" if no response was received
+	OverwatchRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "overwatch_requests_total",
+		Help:      "Number of requests to the Overwatch stats api, by status code.",
+	}, []string{"code"})
+)
+
+func init() {
+	prometheus.MustRegister(
+		DiscordConnected,
+		PresenceUpdates,
+		SessionsStarted,
+		SessionsReported,
+		PendingReports,
+		StatsRetries,
+		SessionSnapshots,
+		LinkedPlayers,
+		OverwatchRequestDuration,
+		OverwatchRequests,
+	)
+}
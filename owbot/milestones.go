@@ -0,0 +1,278 @@
+package owbot
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/snakelayer/discord-oversessions/owbot/overwatch"
+	"github.com/snakelayer/discord-oversessions/owbot/player"
+	"github.com/snakelayer/discord-oversessions/owbot/store"
+)
+
+// Kinds of announcements, which guild admins can turn on and off
+const (
+	announceStreak       = "streak"
+	announcePersonalBest = "personalbest"
+	announceTier         = "tier"
+	announceLevel        = "level"
+	announceGames        = "games"
+)
+
+var announcementKinds = []string{announceStreak, announcePersonalBest, announceTier, announceLevel, announceGames}
+
+var announcementDescriptions = map[string]string{
+	announceStreak:       "comp games won or lost in a row",
+	announcePersonalBest: "new highest SR",
+	announceTier:         "promotion to a higher rank tier",
+	announceLevel:        "level and prestige ups",
+	announceGames:        "round numbers of comp games played in a season",
+}
+
+// Posts an announcement for each notable event of the stored session that
+// the guild has not turned off, as the player chose to share their reports.
+// SR milestones of players hiding their SR are not announced.
+func (bot *Bot) announceMilestones(prev *player.PlayerState, next *player.PlayerState, record store.SessionRecord, privacy store.Privacy) {
+	settings, err := bot.store.GetGuildSettings(bot.discord.GetGuildId())
+	if err != nil {
+		bot.logger.WithError(err).Error("could not get guild settings")
+		return
+	}
+	enabled := func(kind string) bool {
+		if (privacy.HideSR() || record.Alt) && (kind == announcePersonalBest || kind == announceTier) {
+			return false
+		}
+		enabled, ok := settings.Announcements[kind]
+		return !ok || enabled
+	}
+
+	milestones := bot.getConfig().Milestones
+	name := next.User.Username
+	var announcements []string
+
+	if enabled(announceStreak) || enabled(announcePersonalBest) {
+		records, err := bot.store.GetSessions(record.UserId, time.Time{})
+		if err != nil {
+			bot.logger.WithError(err).WithField("userId", record.UserId).Error("could not get sessions")
+			records = nil
+		}
+
+		wins, losses := getStreak(records)
+		if enabled(announceStreak) && milestones.StreakLength > 0 {
+			compWDL := record.CompWDL()
+			if compWDL.Win > 0 && wins >= milestones.StreakLength {
+				announcements = append(announcements, fmt.Sprintf(":fire: **%s** is on a %d game win streak!", name, wins))
+			} else if compWDL.Loss > 0 && losses >= milestones.StreakLength {
+				announcements = append(announcements, fmt.Sprintf("**%s** has lost %d games in a row, maybe take a break?", name, losses))
+			}
+		}
+
+		if best := getPreviousBestSR(records); enabled(announcePersonalBest) && best != 0 && record.FinalSR > best {
+			announcements = append(announcements, fmt.Sprintf(":trophy: **%s** reached a new personal best of %d SR!", name, record.FinalSR))
+		}
+	}
+
+	if enabled(announceTier) {
+		prevTier, prevOk := overwatch.GetTier(record.InitialSR)
+		nextTier, nextOk := overwatch.GetTier(record.FinalSR)
+		if prevOk && nextOk && nextTier.MinSR > prevTier.MinSR {
+			announcements = append(announcements, fmt.Sprintf(":tada: **%s** made it to %s!", name, nextTier.Name))
+		}
+	}
+
+	if enabled(announceLevel) {
+		prevPrestige, prevLevel := prev.RegionBlob.GetLevel()
+		nextPrestige, nextLevel := next.RegionBlob.GetLevel()
+		if nextPrestige > prevPrestige {
+			announcements = append(announcements, fmt.Sprintf(":star: **%s** reached prestige %d!", name, nextPrestige))
+		} else if interval := milestones.LevelInterval; interval > 0 && nextLevel/interval > prevLevel/interval {
+			announcements = append(announcements, fmt.Sprintf(":star: **%s** reached level %d!", name, nextLevel/interval*interval))
+		}
+	}
+
+	if interval := milestones.GamesInterval; enabled(announceGames) && interval > 0 {
+		prevGames, nextGames := prev.RegionBlob.GetCompGames(), next.RegionBlob.GetCompGames()
+		if overwatch.IsNewSeason(prev.RegionBlob, next.RegionBlob) {
+			prevGames = 0
+		}
+		if nextGames/interval > prevGames/interval {
+			announcements = append(announcements, fmt.Sprintf(":video_game: **%s** played their %s comp game this season!", name, ordinal(nextGames/interval*interval)))
+		}
+	}
+
+	for _, announcement := range announcements {
+		bot.sendToPlayer(record.UserId, privacy, announcement)
+	}
+	if len(announcements) > 0 {
+		bot.logger.WithField("userId", record.UserId).WithField("announcements", len(announcements)).Info("posted announcements")
+	}
+}
+
+// Returns the number of comp games won or lost in a row at the end of the
+// sessions, going by the recorded matches in the order they were played.
+// Matches that ended between the same two snapshots have no known order, so
+// they only extend a streak if they all have the same result. Sessions
+// recorded without matches count only if they were all wins or all losses,
+// and sessions without comp games are skipped. A draw ends a streak.
+func getStreak(records []store.SessionRecord) (int, int) {
+	wins, losses := 0, 0
+	// adds games with the same result, false if they end the streak
+	extend := func(result overwatch.MatchResult, games int) bool {
+		switch {
+		case result == overwatch.MatchWin && losses == 0:
+			wins += games
+		case result == overwatch.MatchLoss && wins == 0:
+			losses += games
+		default:
+			return false
+		}
+		return true
+	}
+
+	for i := len(records) - 1; i >= 0; i-- {
+		matches := records[i].Matches
+		if len(matches) == 0 {
+			compWDL := records[i].CompWDL()
+			if compWDL.IsEmpty() {
+				continue
+			}
+
+			result := overwatch.MatchDraw
+			if compWDL.Draw == 0 && compWDL.Loss == 0 {
+				result = overwatch.MatchWin
+			} else if compWDL.Draw == 0 && compWDL.Win == 0 {
+				result = overwatch.MatchLoss
+			}
+			if !extend(result, compWDL.Win+compWDL.Loss) {
+				return wins, losses
+			}
+			continue
+		}
+
+		for j := len(matches) - 1; j >= 0; {
+			// the matches that ended between the same two snapshots
+			first := j
+			for first > 0 && matches[first-1].Time.Equal(matches[j].Time) {
+				first--
+			}
+			for _, match := range matches[first:j] {
+				if match.Result != matches[j].Result {
+					return wins, losses
+				}
+			}
+			if !extend(matches[j].Result, j-first+1) {
+				return wins, losses
+			}
+			j = first - 1
+		}
+	}
+
+	return wins, losses
+}
+
+// Returns the highest SR of all sessions but the last, or 0 if there is
+// none. Sessions on alt accounts are left out.
+func getPreviousBestSR(records []store.SessionRecord) int {
+	best := 0
+	for i := 0; i < len(records)-1; i++ {
+		if records[i].Alt {
+			continue
+		}
+		for _, sr := range []int{records[i].InitialSR, records[i].FinalSR} {
+			if sr > best {
+				best = sr
+			}
+		}
+	}
+
+	return best
+}
+
+// ordinal 1 => "1st", ordinal 12 => "12th", ordinal 22 => "22nd"
+func ordinal(n int) string {
+	suffix := "th"
+	switch {
+	case n%100 >= 11 && n%100 <= 13:
+	case n%10 == 1:
+		suffix = "st"
+	case n%10 == 2:
+		suffix = "nd"
+	case n%10 == 3:
+		suffix = "rd"
+	}
+	return fmt.Sprintf("%d%s", n, suffix)
+}
+
+const announceUsage = "usage: `!announce list|on <kind>|off <kind>`"
+
+// Handles the !announce command. Turning announcements on or off requires
+// the user to be a guild admin.
+func (bot *Bot) announceCommand(message *discordgo.Message, args string) {
+	bot.logger.WithField("user", message.Author).WithField("args", args).Info("announce request")
+
+	input := strings.Fields(args)
+	switch {
+	case len(input) == 1 && input[0] == "list":
+		bot.listAnnouncements()
+	case len(input) == 2 && (input[0] == "on" || input[0] == "off"):
+		if !bot.hasPermission(message, actionChangeAnnouncements) {
+			return
+		}
+		bot.setGuildAnnouncement(strings.ToLower(input[1]), input[0] == "on")
+	default:
+		bot.discord.CreateMessage(announceUsage)
+	}
+}
+
+func (bot *Bot) listAnnouncements() {
+	settings, err := bot.store.GetGuildSettings(bot.discord.GetGuildId())
+	if err != nil {
+		bot.logger.WithError(err).Error("could not get guild settings")
+		return
+	}
+
+	var buffer bytes.Buffer
+	buffer.WriteString("announcements:")
+	for _, kind := range announcementKinds {
+		state := "on"
+		if enabled, ok := settings.Announcements[kind]; ok && !enabled {
+			state = "off"
+		}
+		fmt.Fprintf(&buffer, "\n%s (%s): %s", kind, announcementDescriptions[kind], state)
+	}
+	bot.discord.CreateMessage(buffer.String())
+}
+
+func (bot *Bot) setGuildAnnouncement(kind string, enabled bool) {
+	if _, ok := announcementDescriptions[kind]; !ok {
+		bot.discord.CreateMessage(kind + " is not an announcement, try `!announce list`")
+		return
+	}
+
+	guildId := bot.discord.GetGuildId()
+	settings, err := bot.store.GetGuildSettings(guildId)
+	if err != nil {
+		bot.logger.WithError(err).Error("could not get guild settings")
+		return
+	}
+
+	if enabled {
+		delete(settings.Announcements, kind)
+	} else {
+		settings.Announcements[kind] = false
+	}
+
+	if err := bot.store.PutGuildSettings(guildId, settings); err != nil {
+		bot.logger.WithError(err).Error("could not store guild settings")
+		bot.discord.CreateMessage("could not save announcement setting for " + kind)
+		return
+	}
+
+	if enabled {
+		bot.discord.CreateMessage(kind + " announcements are now on")
+	} else {
+		bot.discord.CreateMessage(kind + " announcements are now off")
+	}
+}
@@ -0,0 +1,107 @@
+package owbot
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/snakelayer/discord-oversessions/owbot/overwatch"
+	"github.com/snakelayer/discord-oversessions/owbot/store"
+)
+
+// Returns a session with a match for each result, the matches of a string
+// like "W W|L" ending between different snapshots except where joined by
+// "|"
+func matchesSession(start time.Time, results string) store.SessionRecord {
+	record := store.SessionRecord{Start: start, HeroesWDL: map[string]overwatch.WDL{}}
+	matchTime := start
+	for _, snapshot := range splitFields(results, " ") {
+		matchTime = matchTime.Add(20 * time.Minute)
+		for _, result := range splitFields(snapshot, "|") {
+			record.Matches = append(record.Matches, overwatch.Match{Time: matchTime, Result: overwatch.MatchResult(result)})
+			wdl := record.HeroesWDL["mercy"]
+			switch overwatch.MatchResult(result) {
+			case overwatch.MatchWin:
+				wdl.Win++
+			case overwatch.MatchDraw:
+				wdl.Draw++
+			case overwatch.MatchLoss:
+				wdl.Loss++
+			}
+			record.HeroesWDL["mercy"] = wdl
+		}
+	}
+	return record
+}
+
+func splitFields(s string, sep string) []string {
+	var fields []string
+	for _, field := range strings.Split(s, sep) {
+		if field != "" {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// Returns a session stored without matches
+func wdlSession(start time.Time, wdl overwatch.WDL) store.SessionRecord {
+	return store.SessionRecord{Start: start, HeroesWDL: map[string]overwatch.WDL{"mercy": wdl}}
+}
+
+func TestGetStreak(t *testing.T) {
+	day := func(n int) time.Time {
+		return time.Date(2017, time.June, n, 19, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name    string
+		records []store.SessionRecord
+		wins    int
+		losses  int
+	}{
+		{"no sessions", nil, 0, 0},
+		{"one win", []store.SessionRecord{matchesSession(day(1), "W")}, 1, 0},
+		{"streak at the end of a session", []store.SessionRecord{matchesSession(day(1), "W L L W W")}, 2, 0},
+		{"loss streak", []store.SessionRecord{matchesSession(day(1), "W L L")}, 0, 2},
+		{
+			"streak across sessions",
+			[]store.SessionRecord{matchesSession(day(1), "L W W"), matchesSession(day(2), "W W")},
+			4, 0,
+		},
+		{
+			"sessions without comp games are skipped",
+			[]store.SessionRecord{matchesSession(day(1), "W W"), {Start: day(2)}, matchesSession(day(3), "W")},
+			3, 0,
+		},
+		{"a draw ends a streak", []store.SessionRecord{matchesSession(day(1), "W W D W")}, 1, 0},
+		{"ending on a draw", []store.SessionRecord{matchesSession(day(1), "W W D")}, 0, 0},
+		// matches that ended between the same two snapshots have no order
+		{"same results between snapshots", []store.SessionRecord{matchesSession(day(1), "L W|W")}, 2, 0},
+		{"mixed results between snapshots", []store.SessionRecord{matchesSession(day(1), "W W L|W")}, 0, 0},
+		{"mixed results before the streak", []store.SessionRecord{matchesSession(day(1), "L|W W W")}, 2, 0},
+		// sessions stored without matches only count if all of one result
+		{
+			"session without matches continues a streak",
+			[]store.SessionRecord{wdlSession(day(1), overwatch.WDL{Win: 3}), matchesSession(day(2), "W")},
+			4, 0,
+		},
+		{
+			"mixed session without matches ends a streak",
+			[]store.SessionRecord{wdlSession(day(1), overwatch.WDL{Win: 3, Loss: 1}), matchesSession(day(2), "W W")},
+			2, 0,
+		},
+		{
+			"loss streak across a session without matches",
+			[]store.SessionRecord{matchesSession(day(1), "W L"), wdlSession(day(2), overwatch.WDL{Loss: 2}), matchesSession(day(3), "L")},
+			0, 4,
+		},
+	}
+
+	for _, test := range tests {
+		wins, losses := getStreak(test.records)
+		if wins != test.wins || losses != test.losses {
+			t.Errorf("%s: getStreak = %d wins, %d losses, want %d wins, %d losses", test.name, wins, losses, test.wins, test.losses)
+		}
+	}
+}
@@ -23,6 +23,25 @@ func MakeWDL(prev *HeroStruct, next *HeroStruct) WDL {
 	}
 }
 
+// Quickplay hero stats always have wins but only sometimes losses, and
+// never draws, so unlike MakeWDL this does not infer draws from the games
+// played. Counters that went down, eg. after a profile reset or from stale
+// data, count as no games.
+func MakeQuickplayWDL(prev *HeroStruct, next *HeroStruct) WDL {
+	return WDL{
+		Win:  nonNegative(int(next.GeneralStats.GamesWon - prev.GeneralStats.GamesWon)),
+		Draw: 0,
+		Loss: nonNegative(int(next.GeneralStats.GamesLost - prev.GeneralStats.GamesLost)),
+	}
+}
+
+func nonNegative(value int) int {
+	if value < 0 {
+		return 0
+	}
+	return value
+}
+
 // Top level response to a u/<battle-tag>/blob request
 type BlobResponse struct {
 	US *RegionBlob `json:"us"`
@@ -32,7 +51,7 @@ type RegionBlob struct {
 	Heroes struct {
 		Stats struct {
 			Competitive *AllHeroStats `json:"competitive"`
-			// Quickplay is ignored
+			Quickplay   *AllHeroStats `json:"quickplay"`
 		} `json:"stats"`
 	} `json:"heroes"`
 	Stats struct {
@@ -56,10 +75,64 @@ func (regionBlob *RegionBlob) GetCompRank() int {
 	return regionBlob.Stats.Competitive.OverallStats.CompRank
 }
 
+// Returns the number of comp games played this season
+func (regionBlob *RegionBlob) GetCompGames() int {
+	if regionBlob.Stats.Competitive == nil {
+		return 0
+	}
+	return regionBlob.Stats.Competitive.OverallStats.Games
+}
+
+// Returns the prestige and level of the player. The level restarts at 1
+// with every prestige.
+func (regionBlob *RegionBlob) GetLevel() (int, int) {
+	userStats := regionBlob.Stats.Quickplay
+	if userStats == nil {
+		userStats = regionBlob.Stats.Competitive
+	}
+	if userStats == nil {
+		return 0, 0
+	}
+	return userStats.OverallStats.Prestige, userStats.OverallStats.Level
+}
+
+// Returns the comp wins, draws and losses of the season
+func (regionBlob *RegionBlob) GetCompWDL() WDL {
+	if regionBlob.Stats.Competitive == nil {
+		return WDL{}
+	}
+	overallStats := regionBlob.Stats.Competitive.OverallStats
+	return WDL{
+		Win:  overallStats.Wins,
+		Draw: overallStats.Games - overallStats.Wins - overallStats.Losses,
+		Loss: overallStats.Losses,
+	}
+}
+
+// Returns the comp eliminations per death of the season
+func (regionBlob *RegionBlob) GetCompKPD() float32 {
+	if regionBlob.Stats.Competitive == nil {
+		return 0
+	}
+	return regionBlob.Stats.Competitive.GetKPD()
+}
+
+// Checks if a new comp season started between the two blobs, which resets
+// the comp stats. The comp games count going down is the only sign of it,
+// so both blobs need comp stats; missing stats are not a new season.
+func IsNewSeason(prev *RegionBlob, next *RegionBlob) bool {
+	return prev.Stats.Competitive != nil && next.Stats.Competitive != nil &&
+		next.GetCompGames() < prev.GetCompGames()
+}
+
 func (regionBlob *RegionBlob) GetAllHeroStats() *AllHeroStats {
 	return regionBlob.Heroes.Stats.Competitive
 }
 
+func (regionBlob *RegionBlob) GetQuickplayHeroStats() *AllHeroStats {
+	return regionBlob.Heroes.Stats.Quickplay
+}
+
 func GetQuickplayWDLDiff(prev *RegionBlob, next *RegionBlob) WDL {
 	return WDL{
 		Win:  next.Stats.Quickplay.OverallStats.Wins - prev.Stats.Quickplay.OverallStats.Wins,
@@ -68,6 +141,77 @@ func GetQuickplayWDLDiff(prev *RegionBlob, next *RegionBlob) WDL {
 	}
 }
 
+// GameStatsDiff is the change in the comp game stats of a player over
+// a number of games
+type GameStatsDiff struct {
+	Games        int     `json:"games"`
+	Eliminations float32 `json:"eliminations"`
+	Deaths       float32 `json:"deaths"`
+	SoloKills    float32 `json:"soloKills"`
+	Medals       float32 `json:"medals"`
+	MedalsGold   float32 `json:"medalsGold"`
+	MedalsSilver float32 `json:"medalsSilver"`
+	MedalsBronze float32 `json:"medalsBronze"`
+	// Hours played
+	TimePlayed float32 `json:"timePlayed"`
+
+	// Eliminations per death over the whole season
+	CareerKPD float32 `json:"careerKPD"`
+}
+
+// Returns the change in comp game stats between the blobs. If a new season
+// started in between, the stats are counted from zero. False if no comp
+// games were played.
+func GetCompGameStatsDiff(prev *RegionBlob, next *RegionBlob) (GameStatsDiff, bool) {
+	if next.Stats.Competitive == nil {
+		return GameStatsDiff{}, false
+	}
+
+	var prevStats UserStats
+	if prev.Stats.Competitive != nil && !IsNewSeason(prev, next) {
+		prevStats = *prev.Stats.Competitive
+	}
+	nextStats := *next.Stats.Competitive
+
+	diff := GameStatsDiff{
+		Games:        nextStats.OverallStats.Games - prevStats.OverallStats.Games,
+		Eliminations: nextStats.GameStats.Eliminations - prevStats.GameStats.Eliminations,
+		Deaths:       nextStats.GameStats.Deaths - prevStats.GameStats.Deaths,
+		SoloKills:    nextStats.GameStats.SoloKills - prevStats.GameStats.SoloKills,
+		Medals:       nextStats.GameStats.Medals - prevStats.GameStats.Medals,
+		MedalsGold:   nextStats.GameStats.MedalsGold - prevStats.GameStats.MedalsGold,
+		MedalsSilver: nextStats.GameStats.MedalsSilver - prevStats.GameStats.MedalsSilver,
+		MedalsBronze: nextStats.GameStats.MedalsBronze - prevStats.GameStats.MedalsBronze,
+		TimePlayed:   nextStats.GameStats.TimePlayed - prevStats.GameStats.TimePlayed,
+		CareerKPD:    nextStats.GetKPD(),
+	}
+
+	return diff, diff.Games > 0
+}
+
+func (diff GameStatsDiff) EliminationsPerGame() float32 {
+	if diff.Games == 0 {
+		return 0
+	}
+	return diff.Eliminations / float32(diff.Games)
+}
+
+func (diff GameStatsDiff) DeathsPerGame() float32 {
+	if diff.Games == 0 {
+		return 0
+	}
+	return diff.Deaths / float32(diff.Games)
+}
+
+// Eliminations per death over the games. Without deaths, this is the
+// number of eliminations
+func (diff GameStatsDiff) KPD() float32 {
+	if diff.Deaths == 0 {
+		return diff.Eliminations
+	}
+	return diff.Eliminations / diff.Deaths
+}
+
 type UserStats struct {
 	OverallStats struct {
 		CompRank int     `json:"comprank"`
@@ -91,6 +235,14 @@ type UserStats struct {
 	} `json:"game_stats"`
 }
 
+// Returns the eliminations per death, computing it if owapi left it out
+func (userStats UserStats) GetKPD() float32 {
+	if userStats.GameStats.KPD == 0 && userStats.GameStats.Deaths > 0 {
+		return userStats.GameStats.Eliminations / userStats.GameStats.Deaths
+	}
+	return userStats.GameStats.KPD
+}
+
 func (userStats UserStats) String() string {
 	return fmt.Sprintf("{OverallStats:%v}", userStats.OverallStats)
 }
@@ -150,14 +302,98 @@ func (allHeroStats AllHeroStats) String() string {
 		allHeroStats.Zenyatta)
 }
 
+// Returns the stats of the hero with the given id, or nil if the hero has
+// not been played
+func (allHeroStats *AllHeroStats) HeroStats(heroId string) *HeroStruct {
+	if allHeroStats == nil {
+		return nil
+	}
+
+	switch heroId {
+	case "ana":
+		return allHeroStats.Ana
+	case "bastion":
+		return allHeroStats.Bastion
+	case "dva":
+		return allHeroStats.Dva
+	case "genji":
+		return allHeroStats.Genji
+	case "hanzo":
+		return allHeroStats.Hanzo
+	case "junkrat":
+		return allHeroStats.Junkrat
+	case "lucio":
+		return allHeroStats.Lucio
+	case "mccree":
+		return allHeroStats.Mccree
+	case "mei":
+		return allHeroStats.Mei
+	case "mercy":
+		return allHeroStats.Mercy
+	case "orisa":
+		return allHeroStats.Orisa
+	case "pharah":
+		return allHeroStats.Pharah
+	case "reaper":
+		return allHeroStats.Reaper
+	case "reinhardt":
+		return allHeroStats.Reinhardt
+	case "roadhog":
+		return allHeroStats.Roadhog
+	case "soldier76":
+		return allHeroStats.Soldier76
+	case "sombra":
+		return allHeroStats.Sombra
+	case "symmetra":
+		return allHeroStats.Symmetra
+	case "torbjorn":
+		return allHeroStats.Torbjorn
+	case "tracer":
+		return allHeroStats.Tracer
+	case "widowmaker":
+		return allHeroStats.Widowmaker
+	case "winston":
+		return allHeroStats.Winston
+	case "zarya":
+		return allHeroStats.Zarya
+	case "zenyatta":
+		return allHeroStats.Zenyatta
+	}
+
+	return nil
+}
+
+// Returns the ids of the heroes with games played, ordered by SortHeroIds
+func (allHeroStats *AllHeroStats) HeroesByGamesPlayed() []string {
+	var heroIds []string
+	for _, hero := range Heroes {
+		if heroStats := allHeroStats.HeroStats(hero.Id); heroStats != nil && heroStats.GeneralStats.GamesPlayed > 0 {
+			heroIds = append(heroIds, hero.Id)
+		}
+	}
+	SortHeroIds(heroIds, func(heroId string) int {
+		return int(allHeroStats.HeroStats(heroId).GeneralStats.GamesPlayed)
+	})
+
+	return heroIds
+}
+
 type HeroStruct struct {
-	// AverageStats is ignored
+	// Averages keyed by the owapi stat name, eg. "eliminations_average".
+	// The stats differ between heroes.
+	AverageStats map[string]float32 `json:"average_stats"`
 	GeneralStats struct {
-		GamesLost   float32 `json:"games_lost"`
-		GamesPlayed float32 `json:"games_played"`
-		GamesWon    float32 `json:"games_won"`
+		Deaths        float32 `json:"deaths"`
+		Eliminations  float32 `json:"eliminations"`
+		GamesLost     float32 `json:"games_lost"`
+		GamesPlayed   float32 `json:"games_played"`
+		GamesWon      float32 `json:"games_won"`
+		Medals        float32 `json:"medals"`
+		TimePlayed    float32 `json:"time_played"`
+		WinPercentage float32 `json:"win_percentage"`
 	} `json:"general_stats"`
-	// HeroStats is ignored
+	// Hero specific stats, eg. "players_resurrected" for Mercy
+	HeroStats map[string]float32 `json:"hero_stats"`
 }
 
 func (heroStruct HeroStruct) String() string {
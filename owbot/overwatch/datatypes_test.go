@@ -0,0 +1,58 @@
+package overwatch
+
+import "testing"
+
+func TestIsNewSeason(t *testing.T) {
+	noComp := &RegionBlob{}
+
+	tests := []struct {
+		name string
+		prev *RegionBlob
+		next *RegionBlob
+		want bool
+	}{
+		{"more games", compBlob(t, 2500, WDL{Win: 5, Loss: 5}, nil), compBlob(t, 2520, WDL{Win: 6, Loss: 5}, nil), false},
+		{"same games", compBlob(t, 2500, WDL{Win: 5, Loss: 5}, nil), compBlob(t, 2500, WDL{Win: 5, Loss: 5}, nil), false},
+		{"fewer games", compBlob(t, 2500, WDL{Win: 5, Loss: 5}, nil), compBlob(t, 0, WDL{Win: 1}, nil), true},
+		{"comp stats reset to zero games", compBlob(t, 2500, WDL{Win: 5, Loss: 5}, nil), compBlob(t, 0, WDL{}, nil), true},
+		// a blob without comp stats, eg. from a failed or partial fetch,
+		// says nothing about the season
+		{"next without comp stats", compBlob(t, 2500, WDL{Win: 5, Loss: 5}, nil), noComp, false},
+		{"prev without comp stats", noComp, compBlob(t, 2500, WDL{Win: 5, Loss: 5}, nil), false},
+		{"neither with comp stats", noComp, noComp, false},
+	}
+
+	for _, test := range tests {
+		if newSeason := IsNewSeason(test.prev, test.next); newSeason != test.want {
+			t.Errorf("%s: IsNewSeason = %v, want %v", test.name, newSeason, test.want)
+		}
+	}
+}
+
+func TestMakeQuickplayWDL(t *testing.T) {
+	hero := func(won float32, lost float32) *HeroStruct {
+		heroStruct := &HeroStruct{}
+		heroStruct.GeneralStats.GamesWon = won
+		heroStruct.GeneralStats.GamesLost = lost
+		heroStruct.GeneralStats.GamesPlayed = won + lost
+		return heroStruct
+	}
+
+	tests := []struct {
+		name string
+		prev *HeroStruct
+		next *HeroStruct
+		want WDL
+	}{
+		{"wins and losses", hero(3, 2), hero(5, 3), WDL{Win: 2, Loss: 1}},
+		{"no games", hero(3, 2), hero(3, 2), WDL{}},
+		// eg. after a profile reset or from stale data
+		{"counters going down", hero(10, 8), hero(2, 9), WDL{Loss: 1}},
+	}
+
+	for _, test := range tests {
+		if wdl := MakeQuickplayWDL(test.prev, test.next); wdl != test.want {
+			t.Errorf("%s: MakeQuickplayWDL = %+v, want %+v", test.name, wdl, test.want)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package overwatch
+
+import (
+	"sort"
+	"strings"
+)
+
+// Role is the part a hero plays in a team
+type Role string
+
+const (
+	RoleTank    Role = "tank"
+	RoleDamage  Role = "damage"
+	RoleSupport Role = "support"
+)
+
+// The roles in the order heroes are listed by
+var Roles = []Role{RoleTank, RoleDamage, RoleSupport}
+
+// Hero holds the metadata of a hero. The Id matches the hero's key in the
+// owapi responses.
+type Hero struct {
+	Id           string
+	Name         string
+	Abbreviation string
+	Role         Role
+}
+
+// Roles follow the game as of the owapi v3 data the bot reads, eg.
+// Symmetra is a support hero
+var Heroes = []Hero{
+	{Id: "ana", Name: "Ana", Abbreviation: "Ana", Role: RoleSupport},
+	{Id: "bastion", Name: "Bastion", Abbreviation: "Bas", Role: RoleDamage},
+	{Id: "dva", Name: "D.Va", Abbreviation: "DVa", Role: RoleTank},
+	{Id: "genji", Name: "Genji", Abbreviation: "Gen", Role: RoleDamage},
+	{Id: "hanzo", Name: "Hanzo", Abbreviation: "Han", Role: RoleDamage},
+	{Id: "junkrat", Name: "Junkrat", Abbreviation: "Junk", Role: RoleDamage},
+	{Id: "lucio", Name: "Lúcio", Abbreviation: "Luc", Role: RoleSupport},
+	{Id: "mccree", Name: "McCree", Abbreviation: "McC", Role: RoleDamage},
+	{Id: "mei", Name: "Mei", Abbreviation: "Mei", Role: RoleDamage},
+	{Id: "mercy", Name: "Mercy", Abbreviation: "Mrc", Role: RoleSupport},
+	{Id: "orisa", Name: "Orisa", Abbreviation: "Ori", Role: RoleTank},
+	{Id: "pharah", Name: "Pharah", Abbreviation: "Pha", Role: RoleDamage},
+	{Id: "reaper", Name: "Reaper", Abbreviation: "Rea", Role: RoleDamage},
+	{Id: "reinhardt", Name: "Reinhardt", Abbreviation: "Rein", Role: RoleTank},
+	{Id: "roadhog", Name: "Roadhog", Abbreviation: "Hog", Role: RoleTank},
+	{Id: "soldier76", Name: "Soldier: 76", Abbreviation: "S76", Role: RoleDamage},
+	{Id: "sombra", Name: "Sombra", Abbreviation: "Som", Role: RoleDamage},
+	{Id: "symmetra", Name: "Symmetra", Abbreviation: "Sym", Role: RoleSupport},
+	{Id: "torbjorn", Name: "Torbjörn", Abbreviation: "Torb", Role: RoleDamage},
+	{Id: "tracer", Name: "Tracer", Abbreviation: "Tra", Role: RoleDamage},
+	{Id: "widowmaker", Name: "Widowmaker", Abbreviation: "Widow", Role: RoleDamage},
+	{Id: "winston", Name: "Winston", Abbreviation: "Win", Role: RoleTank},
+	{Id: "zarya", Name: "Zarya", Abbreviation: "Zar", Role: RoleTank},
+	{Id: "zenyatta", Name: "Zenyatta", Abbreviation: "Zen", Role: RoleSupport},
+}
+
+// Returns the hero with the given id
+func GetHero(heroId string) (Hero, bool) {
+	for _, hero := range Heroes {
+		if hero.Id == heroId {
+			return hero, true
+		}
+	}
+
+	return Hero{}, false
+}
+
+// Finds the hero by id, name or abbreviation, ignoring case and punctuation,
+// eg. "Soldier: 76", "s76" and "soldier76" all find Soldier: 76
+func FindHero(name string) (Hero, bool) {
+	name = normalizeHeroName(name)
+	for _, hero := range Heroes {
+		if name == hero.Id || name == normalizeHeroName(hero.Name) || name == normalizeHeroName(hero.Abbreviation) {
+			return hero, true
+		}
+	}
+
+	return Hero{}, false
+}
+
+// Lower cases the name, keeping only ascii letters and digits
+func normalizeHeroName(name string) string {
+	var normalized []byte
+	for _, c := range []byte(strings.ToLower(name)) {
+		if (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') {
+			normalized = append(normalized, c)
+		}
+	}
+	return string(normalized)
+}
+
+// Sorts the hero ids by games, most first, then by role in the order of
+// Roles, then by name. Unknown heroes go after the known ones with as many
+// games.
+func SortHeroIds(heroIds []string, games func(heroId string) int) {
+	sort.Slice(heroIds, func(i, j int) bool {
+		gamesI, gamesJ := games(heroIds[i]), games(heroIds[j])
+		if gamesI != gamesJ {
+			return gamesI > gamesJ
+		}
+
+		roleI, nameI := heroSortKey(heroIds[i])
+		roleJ, nameJ := heroSortKey(heroIds[j])
+		if roleI != roleJ {
+			return roleI < roleJ
+		}
+		return nameI < nameJ
+	})
+}
+
+// The index of the hero's role in Roles, or len(Roles) for unknown heroes,
+// and the id, which orders like the name without its case and accents
+func heroSortKey(heroId string) (int, string) {
+	if hero, ok := GetHero(heroId); ok {
+		for i, role := range Roles {
+			if hero.Role == role {
+				return i, heroId
+			}
+		}
+	}
+	return len(Roles), heroId
+}
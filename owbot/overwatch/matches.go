@@ -0,0 +1,117 @@
+package overwatch
+
+import "time"
+
+// Snapshot is the stats of a player at a point in time
+type Snapshot struct {
+	Time       time.Time
+	RegionBlob *RegionBlob
+}
+
+// MatchResult is the result of a comp match: "W", "D" or "L"
+type MatchResult string
+
+const (
+	MatchWin  MatchResult = "W"
+	MatchDraw MatchResult = "D"
+	MatchLoss MatchResult = "L"
+)
+
+// Match is a comp match inferred from the change between two snapshots
+type Match struct {
+	// Time of the first snapshot the match showed up in. The match ended
+	// between the previous snapshot and this time.
+	Time   time.Time   `json:"time"`
+	Result MatchResult `json:"result"`
+	// Heroes whose counters of the result moved. When several matches
+	// ended between two snapshots, each of them lists all heroes with that
+	// result.
+	Heroes []string `json:"heroes"`
+	// SR change of the match, known only if it was the only match between
+	// the two snapshots and both have an SR
+	SRDiff  int  `json:"srDiff"`
+	SRKnown bool `json:"srKnown"`
+}
+
+// Infers the comp matches played over the snapshots, which are in the order
+// they were taken. Matches that ended between the same two snapshots are
+// ordered wins, then draws, then losses, as their actual order is unknown.
+func InferMatches(snapshots []Snapshot) []Match {
+	var matches []Match
+	for i := 1; i < len(snapshots); i++ {
+		matches = append(matches, inferSnapshotMatches(snapshots[i-1], snapshots[i])...)
+	}
+
+	return matches
+}
+
+func inferSnapshotMatches(prev Snapshot, next Snapshot) []Match {
+	if prev.RegionBlob == nil || next.RegionBlob == nil {
+		return nil
+	}
+
+	prevWDL := prev.RegionBlob.GetCompWDL()
+	prevSR := prev.RegionBlob.GetCompRank()
+	prevHeroStats := prev.RegionBlob.GetAllHeroStats()
+	if IsNewSeason(prev.RegionBlob, next.RegionBlob) {
+		prevWDL = WDL{}
+		prevSR = 0
+		prevHeroStats = nil
+	}
+	nextWDL := next.RegionBlob.GetCompWDL()
+	nextSR := next.RegionBlob.GetCompRank()
+
+	// the overall counters tell the number and results of the matches,
+	// the hero counters only who was played in them
+	wdl := WDL{
+		Win:  nextWDL.Win - prevWDL.Win,
+		Draw: nextWDL.Draw - prevWDL.Draw,
+		Loss: nextWDL.Loss - prevWDL.Loss,
+	}
+	if wdl.Win < 0 || wdl.Draw < 0 || wdl.Loss < 0 {
+		return nil
+	}
+
+	heroesWDL := make(map[string]WDL)
+	nextHeroStats := next.RegionBlob.GetAllHeroStats()
+	for _, hero := range Heroes {
+		nextHero := nextHeroStats.HeroStats(hero.Id)
+		if nextHero == nil {
+			continue
+		}
+		prevHero := prevHeroStats.HeroStats(hero.Id)
+		if prevHero == nil {
+			prevHero = &HeroStruct{}
+		}
+		heroesWDL[hero.Id] = MakeWDL(prevHero, nextHero)
+	}
+
+	heroesWith := func(count func(WDL) int) []string {
+		var heroIds []string
+		for heroId, wdl := range heroesWDL {
+			if count(wdl) > 0 {
+				heroIds = append(heroIds, heroId)
+			}
+		}
+		SortHeroIds(heroIds, func(heroId string) int { return count(heroesWDL[heroId]) })
+		return heroIds
+	}
+
+	var matches []Match
+	addMatches := func(result MatchResult, games int, count func(WDL) int) {
+		heroIds := heroesWith(count)
+		for i := 0; i < games; i++ {
+			matches = append(matches, Match{Time: next.Time, Result: result, Heroes: heroIds})
+		}
+	}
+	addMatches(MatchWin, wdl.Win, func(wdl WDL) int { return wdl.Win })
+	addMatches(MatchDraw, wdl.Draw, func(wdl WDL) int { return wdl.Draw })
+	addMatches(MatchLoss, wdl.Loss, func(wdl WDL) int { return wdl.Loss })
+
+	if len(matches) == 1 && prevSR != 0 && nextSR != 0 {
+		matches[0].SRDiff = nextSR - prevSR
+		matches[0].SRKnown = true
+	}
+
+	return matches
+}
@@ -0,0 +1,147 @@
+package overwatch
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Returns a blob with the comp SR and W/D/L of the season, and the W/D/L
+// of each hero played
+func compBlob(t *testing.T, sr int, wdl WDL, heroesWDL map[string]WDL) *RegionBlob {
+	heroStats := make(map[string]interface{})
+	for heroId, heroWDL := range heroesWDL {
+		heroStats[heroId] = map[string]interface{}{
+			"general_stats": map[string]int{
+				"games_won":    heroWDL.Win,
+				"games_lost":   heroWDL.Loss,
+				"games_played": heroWDL.Win + heroWDL.Draw + heroWDL.Loss,
+			},
+		}
+	}
+	data, err := json.Marshal(map[string]interface{}{
+		"heroes": map[string]interface{}{
+			"stats": map[string]interface{}{"competitive": heroStats},
+		},
+		"stats": map[string]interface{}{
+			"competitive": map[string]interface{}{
+				"overall_stats": map[string]int{
+					"comprank": sr,
+					"wins":     wdl.Win,
+					"losses":   wdl.Loss,
+					"games":    wdl.Win + wdl.Draw + wdl.Loss,
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var regionBlob RegionBlob
+	if err := json.Unmarshal(data, &regionBlob); err != nil {
+		t.Fatal(err)
+	}
+	return &regionBlob
+}
+
+var matchTime = time.Date(2017, time.June, 1, 19, 0, 0, 0, time.UTC)
+
+func TestInferSnapshotMatches(t *testing.T) {
+	prevHeroes := map[string]WDL{"mercy": {Win: 5, Loss: 4}, "ana": {Win: 2, Draw: 1, Loss: 3}}
+
+	tests := []struct {
+		name string
+		prev *RegionBlob
+		next *RegionBlob
+		want []Match
+	}{
+		{
+			"one match has its SR change",
+			compBlob(t, 2500, WDL{Win: 7, Draw: 1, Loss: 7}, prevHeroes),
+			compBlob(t, 2524, WDL{Win: 8, Draw: 1, Loss: 7}, map[string]WDL{"mercy": {Win: 6, Loss: 4}, "ana": {Win: 2, Draw: 1, Loss: 3}}),
+			[]Match{{Time: matchTime, Result: MatchWin, Heroes: []string{"mercy"}, SRDiff: 24, SRKnown: true}},
+		},
+		{
+			"several matches are ordered by result without SR",
+			compBlob(t, 2500, WDL{Win: 7, Draw: 1, Loss: 7}, prevHeroes),
+			compBlob(t, 2480, WDL{Win: 9, Draw: 1, Loss: 8}, map[string]WDL{"mercy": {Win: 7, Loss: 4}, "ana": {Win: 2, Draw: 1, Loss: 4}}),
+			[]Match{
+				{Time: matchTime, Result: MatchWin, Heroes: []string{"mercy"}},
+				{Time: matchTime, Result: MatchWin, Heroes: []string{"mercy"}},
+				{Time: matchTime, Result: MatchLoss, Heroes: []string{"ana"}},
+			},
+		},
+		{
+			"a draw",
+			compBlob(t, 2500, WDL{Win: 7, Draw: 1, Loss: 7}, prevHeroes),
+			compBlob(t, 2500, WDL{Win: 7, Draw: 2, Loss: 7}, map[string]WDL{"mercy": {Win: 5, Loss: 4}, "ana": {Win: 2, Draw: 2, Loss: 3}}),
+			[]Match{{Time: matchTime, Result: MatchDraw, Heroes: []string{"ana"}, SRKnown: true}},
+		},
+		{
+			"heroes switched within a match are all listed",
+			compBlob(t, 2500, WDL{Win: 7, Draw: 1, Loss: 7}, prevHeroes),
+			compBlob(t, 2522, WDL{Win: 8, Draw: 1, Loss: 7}, map[string]WDL{"mercy": {Win: 6, Loss: 4}, "ana": {Win: 3, Draw: 1, Loss: 3}}),
+			[]Match{{Time: matchTime, Result: MatchWin, Heroes: []string{"ana", "mercy"}, SRDiff: 22, SRKnown: true}},
+		},
+		{
+			"hero counters behind the overall counters",
+			compBlob(t, 2500, WDL{Win: 7, Draw: 1, Loss: 7}, prevHeroes),
+			compBlob(t, 2500, WDL{Win: 9, Draw: 1, Loss: 8}, map[string]WDL{"mercy": {Win: 6, Loss: 4}, "ana": {Win: 2, Draw: 1, Loss: 3}}),
+			[]Match{
+				{Time: matchTime, Result: MatchWin, Heroes: []string{"mercy"}},
+				{Time: matchTime, Result: MatchWin, Heroes: []string{"mercy"}},
+				{Time: matchTime, Result: MatchLoss},
+			},
+		},
+		{
+			"unranked matches have no SR change",
+			compBlob(t, 0, WDL{Win: 7, Draw: 1, Loss: 7}, prevHeroes),
+			compBlob(t, 0, WDL{Win: 7, Draw: 1, Loss: 8}, map[string]WDL{"mercy": {Win: 5, Loss: 5}, "ana": {Win: 2, Draw: 1, Loss: 3}}),
+			[]Match{{Time: matchTime, Result: MatchLoss, Heroes: []string{"mercy"}}},
+		},
+		{
+			"counters going down are not matches",
+			compBlob(t, 2500, WDL{Win: 7, Draw: 1, Loss: 7}, prevHeroes),
+			compBlob(t, 2500, WDL{Win: 8, Draw: 1, Loss: 6}, prevHeroes),
+			nil,
+		},
+		{
+			"a new season counts from zero",
+			compBlob(t, 2500, WDL{Win: 7, Draw: 1, Loss: 7}, prevHeroes),
+			compBlob(t, 2300, WDL{Win: 1}, map[string]WDL{"ana": {Win: 1}}),
+			[]Match{{Time: matchTime, Result: MatchWin, Heroes: []string{"ana"}}},
+		},
+	}
+
+	for _, test := range tests {
+		matches := inferSnapshotMatches(Snapshot{Time: matchTime.Add(-time.Hour), RegionBlob: test.prev}, Snapshot{Time: matchTime, RegionBlob: test.next})
+		if !reflect.DeepEqual(matches, test.want) {
+			t.Errorf("%s: got %+v, want %+v", test.name, matches, test.want)
+		}
+	}
+}
+
+func TestInferMatches(t *testing.T) {
+	first := compBlob(t, 2500, WDL{Win: 7, Loss: 7}, map[string]WDL{"mercy": {Win: 7, Loss: 7}})
+	second := compBlob(t, 2475, WDL{Win: 7, Loss: 8}, map[string]WDL{"mercy": {Win: 7, Loss: 8}})
+	third := compBlob(t, 2499, WDL{Win: 8, Loss: 8}, map[string]WDL{"mercy": {Win: 8, Loss: 8}})
+
+	snapshots := []Snapshot{
+		{Time: matchTime, RegionBlob: first},
+		{Time: matchTime.Add(20 * time.Minute), RegionBlob: second},
+		// a failed fetch is skipped over
+		{Time: matchTime.Add(30 * time.Minute), RegionBlob: nil},
+		{Time: matchTime.Add(40 * time.Minute), RegionBlob: second},
+		{Time: matchTime.Add(60 * time.Minute), RegionBlob: third},
+	}
+	want := []Match{
+		{Time: matchTime.Add(20 * time.Minute), Result: MatchLoss, Heroes: []string{"mercy"}, SRDiff: -25, SRKnown: true},
+		{Time: matchTime.Add(60 * time.Minute), Result: MatchWin, Heroes: []string{"mercy"}, SRDiff: 24, SRKnown: true},
+	}
+
+	if matches := InferMatches(snapshots); !reflect.DeepEqual(matches, want) {
+		t.Errorf("InferMatches = %+v, want %+v", matches, want)
+	}
+}
@@ -6,14 +6,12 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Sirupsen/logrus"
-)
-
-const (
-	// The base url of the owapi
-	apiBaseUrl = "https://owapi.net/api/v3/"
+	"github.com/snakelayer/discord-oversessions/owbot/metrics"
 )
 
 // ErrorResponse is an error that is populated with additional error
@@ -42,12 +40,15 @@ type OverwatchClient struct {
 }
 
 // Creates a new OverwatchClient, a rest client for querying a third party
-// overwatch api.
-func NewOverwatchClient(logger *logrus.Logger) (*OverwatchClient, error) {
+// overwatch api at apiBaseUrl.
+func NewOverwatchClient(logger *logrus.Logger, apiBaseUrl string) (*OverwatchClient, error) {
 	// Store the logger as an Entry, adding the module to all log calls
 	overwatchLogger := logger.WithField("module", "overwatch")
 	client := http.DefaultClient
-	baseUrl, _ := url.Parse(apiBaseUrl)
+	baseUrl, err := url.Parse(apiBaseUrl)
+	if err != nil {
+		return nil, err
+	}
 
 	// Create and initialize the next channel with a token. We use a buffer
 	// size of 1 so returning tokens (and the initial add) does not block
@@ -62,6 +63,21 @@ func NewOverwatchClient(logger *logrus.Logger) (*OverwatchClient, error) {
 	}, nil
 }
 
+// Changes the api base url used for subsequent requests. Waits for any
+// in-flight request to finish first.
+func (ow *OverwatchClient) SetBaseUrl(apiBaseUrl string) error {
+	baseUrl, err := url.Parse(apiBaseUrl)
+	if err != nil {
+		return err
+	}
+
+	<-ow.nextCh
+	ow.baseUrl = baseUrl
+	ow.nextCh <- true
+
+	return nil
+}
+
 // Takes a response and returns an error if the status code is not within
 // the 200-299 range.
 func CheckResponse(resp *http.Response) error {
@@ -91,10 +107,14 @@ func (ow *OverwatchClient) NewRequest(ctx context.Context, urlStr string) (*http
 // Do sends a request. If v is not nil, the response is treated as JSON and decoded to v.
 // This method blocks until the request is sent and the response is received and parsed.
 func (ow *OverwatchClient) Do(req *http.Request, v interface{}) (*http.Response, error) {
+	start := time.Now()
 	resp, err := ow.client.Do(req)
+	metrics.OverwatchRequestDuration.Observe(time.Since(start).Seconds())
 	if err != nil {
+		metrics.OverwatchRequests.WithLabelValues("error").Inc()
 		return nil, err
 	}
+	metrics.OverwatchRequests.WithLabelValues(strconv.Itoa(resp.StatusCode)).Inc()
 	defer func() {
 		if cerr := resp.Body.Close(); err == nil {
 			err = cerr
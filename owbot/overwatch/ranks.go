@@ -0,0 +1,95 @@
+package overwatch
+
+import "strings"
+
+// Tier is a competitive rank, covering SR from MinSR up to the MinSR of
+// the next tier
+type Tier struct {
+	Name  string
+	MinSR int
+	// Color of the tier, as used by Discord embeds
+	Color int
+	// Url of the tier's icon image
+	Icon string
+}
+
+const tierIconUrl = "https://d1u1mce87gyfbn.cloudfront.net/game/rank-icons/season-2/rank-"
+
+var Tiers = []Tier{
+	{Name: "Bronze", MinSR: 1, Color: 0x9c6a3c, Icon: tierIconUrl + "1.png"},
+	{Name: "Silver", MinSR: 1500, Color: 0xc0c0c0, Icon: tierIconUrl + "2.png"},
+	{Name: "Gold", MinSR: 2000, Color: 0xe5c150, Icon: tierIconUrl + "3.png"},
+	{Name: "Platinum", MinSR: 2500, Color: 0xa8b8c8, Icon: tierIconUrl + "4.png"},
+	{Name: "Diamond", MinSR: 3000, Color: 0x7fb7e8, Icon: tierIconUrl + "5.png"},
+	{Name: "Master", MinSR: 3500, Color: 0xf0a830, Icon: tierIconUrl + "6.png"},
+	{Name: "Grandmaster", MinSR: 4000, Color: 0xf4e9c6, Icon: tierIconUrl + "7.png"},
+}
+
+// Returns the tier of the SR. An SR of 0 means unranked, which has no tier.
+func GetTier(sr int) (Tier, bool) {
+	if sr <= 0 {
+		return Tier{}, false
+	}
+
+	tier := Tiers[0]
+	for _, t := range Tiers {
+		if sr >= t.MinSR {
+			tier = t
+		}
+	}
+	return tier, true
+}
+
+// Returns the tier with the id, eg. "gold"
+func GetTierById(id string) (Tier, bool) {
+	for _, tier := range Tiers {
+		if tier.Id() == id {
+			return tier, true
+		}
+	}
+	return Tier{}, false
+}
+
+// Returns the tier above this one, if there is one
+func (tier Tier) Next() (Tier, bool) {
+	for i, t := range Tiers {
+		if t.Name == tier.Name && i+1 < len(Tiers) {
+			return Tiers[i+1], true
+		}
+	}
+	return Tier{}, false
+}
+
+// The tier id used to find its guild emoji, eg. "grandmaster"
+func (tier Tier) Id() string {
+	return strings.ToLower(tier.Name)
+}
+
+// TierProgress is how far an SR is into its tier
+type TierProgress struct {
+	Tier Tier
+	// The next tier, and the SR still needed to reach it. Zero in the
+	// highest tier
+	Next     Tier
+	SRToNext int
+	// Percentage of the way from the start of the tier to the next one
+	Percent int
+}
+
+// Returns the progress of the SR through its tier. False if unranked.
+func GetTierProgress(sr int) (TierProgress, bool) {
+	tier, ok := GetTier(sr)
+	if !ok {
+		return TierProgress{}, false
+	}
+
+	progress := TierProgress{Tier: tier}
+	if next, ok := tier.Next(); ok {
+		progress.Next = next
+		progress.SRToNext = next.MinSR - sr
+		progress.Percent = (sr - tier.MinSR) * 100 / (next.MinSR - tier.MinSR)
+	} else {
+		progress.Percent = 100
+	}
+	return progress, true
+}
@@ -0,0 +1,72 @@
+package overwatch
+
+import "testing"
+
+func TestGetTier(t *testing.T) {
+	tests := []struct {
+		sr   int
+		tier string
+		ok   bool
+	}{
+		{-1, "", false},
+		{0, "", false},
+		{1, "Bronze", true},
+		{1499, "Bronze", true},
+		{1500, "Silver", true},
+		{1999, "Silver", true},
+		{2000, "Gold", true},
+		{2499, "Gold", true},
+		{2500, "Platinum", true},
+		{2999, "Platinum", true},
+		{3000, "Diamond", true},
+		{3499, "Diamond", true},
+		{3500, "Master", true},
+		{3999, "Master", true},
+		{4000, "Grandmaster", true},
+		{5000, "Grandmaster", true},
+	}
+
+	for _, test := range tests {
+		tier, ok := GetTier(test.sr)
+		if ok != test.ok || tier.Name != test.tier {
+			t.Errorf("GetTier(%d) = %q, %v, want %q, %v", test.sr, tier.Name, ok, test.tier, test.ok)
+		}
+	}
+}
+
+func TestGetTierProgress(t *testing.T) {
+	tests := []struct {
+		sr       int
+		tier     string
+		next     string
+		srToNext int
+		percent  int
+	}{
+		{1, "Bronze", "Silver", 1499, 0},
+		{1499, "Bronze", "Silver", 1, 99},
+		{1500, "Silver", "Gold", 500, 0},
+		{1750, "Silver", "Gold", 250, 50},
+		{1999, "Silver", "Gold", 1, 99},
+		{3999, "Master", "Grandmaster", 1, 99},
+		// the highest tier has no next tier
+		{4000, "Grandmaster", "", 0, 100},
+		{4500, "Grandmaster", "", 0, 100},
+	}
+
+	for _, test := range tests {
+		progress, ok := GetTierProgress(test.sr)
+		if !ok {
+			t.Errorf("GetTierProgress(%d) is unranked", test.sr)
+			continue
+		}
+		if progress.Tier.Name != test.tier || progress.Next.Name != test.next || progress.SRToNext != test.srToNext || progress.Percent != test.percent {
+			t.Errorf("GetTierProgress(%d) = %s, next %q in %d SR, %d%%, want %s, next %q in %d SR, %d%%",
+				test.sr, progress.Tier.Name, progress.Next.Name, progress.SRToNext, progress.Percent,
+				test.tier, test.next, test.srToNext, test.percent)
+		}
+	}
+
+	if _, ok := GetTierProgress(0); ok {
+		t.Error("GetTierProgress(0) is ranked")
+	}
+}
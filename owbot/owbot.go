@@ -1,19 +1,46 @@
 package owbot
 
 import (
+	"net/http"
+	"sync"
+
 	"github.com/Sirupsen/logrus"
+	"github.com/snakelayer/discord-oversessions/owbot/config"
 	"github.com/snakelayer/discord-oversessions/owbot/discord"
+	"github.com/snakelayer/discord-oversessions/owbot/metrics"
 	"github.com/snakelayer/discord-oversessions/owbot/overwatch"
 	"github.com/snakelayer/discord-oversessions/owbot/player"
+	"github.com/snakelayer/discord-oversessions/owbot/store"
 )
 
 // The bot is the main component of the ow-bot. It handles events
 // from Discord and uses the overwatch client to respond to queries.
 type Bot struct {
-	logger       *logrus.Entry
-	overwatch    *overwatch.OverwatchClient
-	discord      *discord.DiscordAdapter
-	playerStates map[string]player.PlayerState
+	logger    *logrus.Entry
+	overwatch *overwatch.OverwatchClient
+	discord   *discord.DiscordAdapter
+	store     *store.Store
+
+	httpServer *http.Server
+
+	// closed to stop posting digests and polling sessions
+	stopDigests chan struct{}
+	stopPolling chan struct{}
+
+	// receives reload requests from !reload, handled by the caller
+	reloadRequests chan struct{}
+
+	// guards config, templateTexts and playerStates, which can be replaced on reload
+	mutex         sync.RWMutex
+	config        *config.Config
+	templateTexts map[string]string
+	playerStates  map[string]player.PlayerState
+
+	reportsMutex   sync.Mutex
+	pendingReports map[string]*pendingReport
+
+	// serializes storing sessions, which may start a new season
+	seasonMutex sync.Mutex
 }
 
 func (bot *Bot) Start() error {
@@ -30,45 +57,130 @@ func (bot *Bot) Start() error {
 	}
 	bot.logger.Debug("Connected to Discord")
 
+	if httpConfig := bot.getConfig().HTTP; httpConfig.Listen != "" {
+		bot.startHTTPServer(httpConfig)
+	}
+
+	bot.stopDigests = make(chan struct{})
+	go bot.runDigests(bot.stopDigests)
+	bot.stopPolling = make(chan struct{})
+	go bot.runSessionPolling(bot.stopPolling)
+
 	return nil
 }
 
 func (bot *Bot) Stop() {
+	bot.stopHTTPServer()
+	if bot.stopDigests != nil {
+		close(bot.stopDigests)
+	}
+	if bot.stopPolling != nil {
+		close(bot.stopPolling)
+	}
+
 	bot.discord.Close()
 	bot.logger.Debug("Disconnected from Discord")
+
+	if err := bot.store.Close(); err != nil {
+		bot.logger.WithError(err).Error("could not close store")
+	}
 }
 
-func NewBot(logger *logrus.Logger, token string, battleTagMap map[string]string) (*Bot, error) {
-	overwatch, err := overwatch.NewOverwatchClient(logger)
+func NewBot(logger *logrus.Logger, config *config.Config, battleTagMap map[string]string) (*Bot, error) {
+	overwatch, err := overwatch.NewOverwatchClient(logger, config.Overwatch.BaseUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	discordAdapter, err := discord.New(logger, config.Token, config.Guild.ChannelPattern)
 	if err != nil {
 		return nil, err
 	}
 
-	discordAdapter, err := discord.New(logger, token)
+	templateTexts, err := loadTemplateFiles(config.Guild.TemplateFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	store, err := store.Open(logger, config.DBFile)
+	if err != nil {
+		return nil, err
+	}
+
+	// links from the battleTag file are stored with the links made by commands
+	if _, _, err := syncFileLinks(store, battleTagMap); err != nil {
+		return nil, err
+	}
+	links, err := store.GetLinks()
 	if err != nil {
 		return nil, err
 	}
 
 	var playerStates = make(map[string]player.PlayerState)
-	for userId, battleTag := range battleTagMap {
-		playerState := player.New(battleTag)
+	for userId, link := range links {
+		playerState := player.New(link.BattleTag)
+		playerState.SetAlts(link.Alts)
 		playerStates[userId] = playerState
-		logger.WithField("userId", userId).WithField("battleTag", battleTag).Debug("initialized player state")
+		logger.WithField("userId", userId).WithField("battleTag", link.BattleTag).Debug("initialized player state")
 	}
+	metrics.LinkedPlayers.Set(float64(len(playerStates)))
 
 	return &Bot{
-		logger:       logger.WithField("module", "main"),
-		overwatch:    overwatch,
-		discord:      discordAdapter,
-		playerStates: playerStates,
+		logger:         logger.WithField("module", "main"),
+		overwatch:      overwatch,
+		discord:        discordAdapter,
+		store:          store,
+		config:         config,
+		templateTexts:  templateTexts,
+		playerStates:   playerStates,
+		pendingReports: make(map[string]*pendingReport),
+		reloadRequests: make(chan struct{}, 1),
 	}, nil
 }
 
 func (bot *Bot) HasBattleTag(userId string) bool {
-	if bot.playerStates[userId].BattleTag == "" {
+	if playerState, _ := bot.getPlayerState(userId); playerState.BattleTag == "" {
 		bot.logger.WithField("userId", userId).Info("no associated battleTag")
 		return false
 	}
 
 	return true
 }
+
+func (bot *Bot) getConfig() *config.Config {
+	bot.mutex.RLock()
+	defer bot.mutex.RUnlock()
+	return bot.config
+}
+
+func (bot *Bot) getPlayerState(userId string) (player.PlayerState, bool) {
+	bot.mutex.RLock()
+	defer bot.mutex.RUnlock()
+	playerState, ok := bot.playerStates[userId]
+	return playerState, ok
+}
+
+// Returns a copy of all player states, safe to iterate while states change
+func (bot *Bot) getPlayerStates() map[string]player.PlayerState {
+	bot.mutex.RLock()
+	defer bot.mutex.RUnlock()
+	playerStates := make(map[string]player.PlayerState, len(bot.playerStates))
+	for userId, playerState := range bot.playerStates {
+		playerStates[userId] = playerState
+	}
+	return playerStates
+}
+
+func (bot *Bot) setPlayerState(userId string, playerState player.PlayerState) {
+	bot.mutex.Lock()
+	defer bot.mutex.Unlock()
+	bot.playerStates[userId] = playerState
+	metrics.LinkedPlayers.Set(float64(len(bot.playerStates)))
+}
+
+func (bot *Bot) deletePlayerState(userId string) {
+	bot.mutex.Lock()
+	defer bot.mutex.Unlock()
+	delete(bot.playerStates, userId)
+	metrics.LinkedPlayers.Set(float64(len(bot.playerStates)))
+}
@@ -0,0 +1,107 @@
+package owbot
+
+import (
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/snakelayer/discord-oversessions/owbot/config"
+)
+
+// What a user may do with the bot, from their guild roles and permissions
+type permissionLevel int
+
+const (
+	permissionMember permissionLevel = iota
+	// Can link and unlink other users
+	permissionModerator
+	// Can change guild settings and the bot's config
+	permissionAdmin
+)
+
+// Actions that need more than the member permission level
+const (
+	actionLinkOthers          = "link other users"
+	actionUnlinkOthers        = "unlink other users"
+	actionSetChannel          = "change the channel"
+	actionShowConfig          = "see the config"
+	actionReload              = "reload the config"
+	actionChangeEmoji         = "change emoji"
+	actionChangeAnnouncements = "change announcements"
+	actionPreviewTemplates    = "preview templates"
+	actionChangeTemplates     = "change templates"
+)
+
+// The lowest permission level allowed to do each action. Actions missing
+// from here need the admin level.
+var actionPermissions = map[string]permissionLevel{
+	actionLinkOthers:          permissionModerator,
+	actionUnlinkOthers:        permissionModerator,
+	actionSetChannel:          permissionAdmin,
+	actionShowConfig:          permissionAdmin,
+	actionReload:              permissionAdmin,
+	actionChangeEmoji:         permissionAdmin,
+	actionChangeAnnouncements: permissionAdmin,
+	actionPreviewTemplates:    permissionAdmin,
+	actionChangeTemplates:     permissionAdmin,
+}
+
+// Returns the lowest permission level allowed to do the action
+func actionPermission(action string) permissionLevel {
+	if level, ok := actionPermissions[action]; ok {
+		return level
+	}
+	return permissionAdmin
+}
+
+// Whether a user with the level may do the action
+func (level permissionLevel) allows(action string) bool {
+	return level >= actionPermission(action)
+}
+
+// Members with the Manage Server permission or one of guild.adminRoles are
+// admins, members with one of guild.moderatorRoles are moderators
+func (bot *Bot) getPermissionLevel(userId string) permissionLevel {
+	if bot.discord.IsGuildAdmin(userId) {
+		return permissionAdmin
+	}
+	return getRolePermissionLevel(bot.getConfig().Guild, bot.discord.GetMemberRoleNames(userId))
+}
+
+// Returns the permission level given by the guild roles, by role name
+func getRolePermissionLevel(guildConfig config.GuildConfig, roleNames []string) permissionLevel {
+	level := permissionMember
+	for _, roleName := range roleNames {
+		if containsFold(guildConfig.AdminRoles, roleName) {
+			return permissionAdmin
+		}
+		if containsFold(guildConfig.ModeratorRoles, roleName) {
+			level = permissionModerator
+		}
+	}
+	return level
+}
+
+// Checks if the author of the message may do the action, replying that
+// only users with the needed permission level can do it if not
+func (bot *Bot) hasPermission(message *discordgo.Message, action string) bool {
+	if bot.getPermissionLevel(message.Author.ID).allows(action) {
+		return true
+	}
+
+	bot.logger.WithField("user", message.Author).WithField("action", action).Info("permission denied")
+	if actionPermission(action) == permissionModerator {
+		bot.reply(message, "only moderators and guild admins can "+action)
+	} else {
+		bot.reply(message, "only guild admins can "+action)
+	}
+	return false
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
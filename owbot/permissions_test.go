@@ -0,0 +1,71 @@
+package owbot
+
+import (
+	"testing"
+
+	"github.com/snakelayer/discord-oversessions/owbot/config"
+)
+
+func TestGetRolePermissionLevel(t *testing.T) {
+	guildConfig := config.GuildConfig{
+		AdminRoles:     []string{"Officers"},
+		ModeratorRoles: []string{"Mods", "Helpers"},
+	}
+
+	tests := []struct {
+		roleNames []string
+		want      permissionLevel
+	}{
+		{nil, permissionMember},
+		{[]string{"Players"}, permissionMember},
+		{[]string{"Helpers"}, permissionModerator},
+		// role names are matched ignoring case
+		{[]string{"mods"}, permissionModerator},
+		{[]string{"officers"}, permissionAdmin},
+		// the highest level of all roles counts
+		{[]string{"Mods", "Officers"}, permissionAdmin},
+		{[]string{"Officers", "Mods"}, permissionAdmin},
+	}
+
+	for _, test := range tests {
+		if level := getRolePermissionLevel(guildConfig, test.roleNames); level != test.want {
+			t.Errorf("getRolePermissionLevel(%v) = %v, want %v", test.roleNames, level, test.want)
+		}
+	}
+
+	if level := getRolePermissionLevel(config.GuildConfig{}, []string{"Officers", "Mods"}); level != permissionMember {
+		t.Errorf("roles without configured role names give level %v, want member", level)
+	}
+}
+
+func TestPermissionLevelAllows(t *testing.T) {
+	tests := []struct {
+		action string
+		// the lowest level that may do the action
+		level permissionLevel
+	}{
+		{actionLinkOthers, permissionModerator},
+		{actionUnlinkOthers, permissionModerator},
+		{actionSetChannel, permissionAdmin},
+		{actionShowConfig, permissionAdmin},
+		{actionReload, permissionAdmin},
+		{actionChangeEmoji, permissionAdmin},
+		{actionChangeAnnouncements, permissionAdmin},
+		{actionPreviewTemplates, permissionAdmin},
+		{actionChangeTemplates, permissionAdmin},
+		// actions nobody listed are limited to admins
+		{"launch the missiles", permissionAdmin},
+	}
+
+	if len(tests)-1 != len(actionPermissions) {
+		t.Errorf("%d actions tested, but %d have permissions", len(tests)-1, len(actionPermissions))
+	}
+
+	for _, test := range tests {
+		for _, level := range []permissionLevel{permissionMember, permissionModerator, permissionAdmin} {
+			if allowed := level.allows(test.action); allowed != (level >= test.level) {
+				t.Errorf("level %v allows %q = %v, want %v", level, test.action, allowed, !allowed)
+			}
+		}
+	}
+}
@@ -9,21 +9,35 @@ import (
 	"github.com/snakelayer/discord-oversessions/owbot/overwatch"
 )
 
-// duration within which a change is considered recent
-var recentDuration = time.Duration(2) * time.Second
-
 type PlayerState struct {
 	UpdateMutex *sync.Mutex
 
 	User *discordgo.User
 	Game *discordgo.Game
 
+	// The player's primary account and its stats
 	BattleTag  string
 	RegionBlob *overwatch.RegionBlob
 
+	// The player's other accounts, with their stats at the start of the
+	// current session
+	Alts []Account
+	// Set on the copies made by ForAlt for an alt account
+	IsAlt bool
+
+	// The stats fetched during the current session, starting with
+	// RegionBlob. Only changed stats are kept.
+	Snapshots []overwatch.Snapshot
+
 	Timestamp time.Time
 }
 
+// An alt account of a player
+type Account struct {
+	BattleTag  string
+	RegionBlob *overwatch.RegionBlob
+}
+
 func New(battleTag string) PlayerState {
 	return PlayerState{
 		BattleTag:   battleTag,
@@ -31,10 +45,56 @@ func New(battleTag string) PlayerState {
 		UpdateMutex: new(sync.Mutex)}
 }
 
-func (state PlayerState) RecentlyUpdated() bool {
+// Replaces the alt accounts, keeping the stats of accounts that stay
+func (state *PlayerState) SetAlts(battleTags []string) {
+	var alts []Account
+	for _, battleTag := range battleTags {
+		account := Account{BattleTag: battleTag}
+		for _, alt := range state.Alts {
+			if alt.BattleTag == battleTag {
+				account.RegionBlob = alt.RegionBlob
+			}
+		}
+		alts = append(alts, account)
+	}
+	state.Alts = alts
+}
+
+// Returns the state as if the alt account at index i were the player's
+// primary account. Snapshots are only taken of the primary account, so the
+// copy has none.
+func (state PlayerState) ForAlt(i int) PlayerState {
+	state.BattleTag = state.Alts[i].BattleTag
+	state.RegionBlob = state.Alts[i].RegionBlob
+	state.Snapshots = nil
+	state.IsAlt = true
+	return state
+}
+
+// Reports whether the state changed within the given duration
+func (state PlayerState) RecentlyUpdated(recentDuration time.Duration) bool {
 	return time.Since(state.Timestamp) < recentDuration
 }
 
+// Starts the snapshots of a session with the current stats
+func (state *PlayerState) StartSnapshots() {
+	state.Snapshots = []overwatch.Snapshot{{Time: state.Timestamp, RegionBlob: state.RegionBlob}}
+}
+
+// Adds the stats as a snapshot if they changed since the last snapshot,
+// returning whether they were added
+func (state *PlayerState) AddSnapshot(t time.Time, regionBlob *overwatch.RegionBlob) bool {
+	if regionBlob == nil {
+		return false
+	}
+	if len(state.Snapshots) > 0 && state.Snapshots[len(state.Snapshots)-1].RegionBlob.Equals(regionBlob) {
+		return false
+	}
+
+	state.Snapshots = append(state.Snapshots, overwatch.Snapshot{Time: t, RegionBlob: regionBlob})
+	return true
+}
+
 func (state PlayerState) String() string {
-	return fmt.Sprintf("{User:%v Game:%v BattleTag:%v Blob:%v Timestamp:%v}", state.User, state.Game, state.BattleTag, state.RegionBlob, state.Timestamp)
+	return fmt.Sprintf("{User:%v Game:%v BattleTag:%v Blob:%v Alts:%d Timestamp:%v}", state.User, state.Game, state.BattleTag, state.RegionBlob, len(state.Alts), state.Timestamp)
 }
@@ -0,0 +1,77 @@
+package owbot
+
+import (
+	"context"
+	"time"
+
+	"github.com/snakelayer/discord-oversessions/owbot/metrics"
+)
+
+// Fetches the stats of the players in a session every session interval
+// until stop is closed. The interval is read from the config before every
+// wait, so that it can be changed by a reload.
+func (bot *Bot) runSessionPolling(stop <-chan struct{}) {
+	for {
+		interval := bot.getConfig().Polling.SessionInterval
+		wait := interval
+		if interval <= 0 {
+			// check again later in case a reload turns polling on
+			wait = time.Minute
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-time.After(wait):
+		}
+
+		if interval > 0 {
+			bot.pollSessions()
+		}
+	}
+}
+
+func (bot *Bot) pollSessions() {
+	for userId, playerState := range bot.getPlayerStates() {
+		if playerState.BattleTag == "" || playerState.RegionBlob == nil || !bot.discord.IsOverwatch(playerState.Game) {
+			continue
+		}
+
+		// the player's lock is held while their session is reported, so
+		// players are polled separately to not hold up the others
+		go bot.pollPlayerSession(userId, playerState.Timestamp)
+	}
+}
+
+// Adds a snapshot of the player's stats to their session, if the session
+// that started at sessionStart is still going
+func (bot *Bot) pollPlayerSession(userId string, sessionStart time.Time) {
+	playerState, ok := bot.getPlayerState(userId)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), bot.getConfig().Polling.CommandTimeout)
+	defer cancel()
+	blob, err := bot.overwatch.GetUSPlayerBlob(ctx, playerState.BattleTag)
+	if err != nil {
+		bot.logger.WithError(err).WithField("userId", userId).Warn("could not poll session stats")
+		return
+	}
+	fetched := time.Now()
+
+	playerState.UpdateMutex.Lock()
+	defer playerState.UpdateMutex.Unlock()
+
+	// the session may have ended or the link changed while fetching
+	currentState, ok := bot.getPlayerState(userId)
+	if !ok || currentState.UpdateMutex != playerState.UpdateMutex || !currentState.Timestamp.Equal(sessionStart) || !bot.discord.IsOverwatch(currentState.Game) {
+		return
+	}
+
+	if currentState.AddSnapshot(fetched, blob) {
+		bot.setPlayerState(userId, currentState)
+		metrics.SessionSnapshots.Inc()
+		bot.logger.WithField("userId", userId).WithField("snapshots", len(currentState.Snapshots)).Debug("added session snapshot")
+	}
+}
@@ -0,0 +1,305 @@
+package owbot
+
+import (
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/snakelayer/discord-oversessions/owbot/overwatch"
+	"github.com/snakelayer/discord-oversessions/owbot/store"
+)
+
+const (
+	privacyUsage  = "usage: `!privacy [public|dm|hidesr]`"
+	deliveryUsage = "usage: `!delivery [channel|dm|both]`"
+)
+
+var privacyDescriptions = map[string]string{
+	store.PrivacyPublic: "session reports are posted in the overwatch channel",
+	store.PrivacyDM:     "session reports are sent to you by direct message, and you are left out of everything posted in the overwatch channel",
+	store.PrivacyHideSR: "session reports are posted in the overwatch channel without your SR",
+}
+
+var deliveryDescriptions = map[string]string{
+	store.DeliveryChannel: "session reports are posted in the overwatch channel",
+	store.DeliveryDM:      "session reports are sent to you by direct message",
+	store.DeliveryBoth:    "session reports are posted in the overwatch channel and sent to you by direct message",
+}
+
+// Returns the privacy settings of the user. If they cannot be read, the
+// user is treated as opted out, so nothing is shared by mistake.
+func (bot *Bot) getPrivacy(userId string) store.Privacy {
+	privacy, err := bot.store.GetPrivacy(userId)
+	if err != nil {
+		bot.logger.WithError(err).WithField("userId", userId).Error("could not get privacy settings")
+		return store.Privacy{OptedOut: true}
+	}
+	return privacy
+}
+
+// Sends a message about the player to the channel, to the player by direct
+// message, or both, as they chose
+func (bot *Bot) sendToPlayer(userId string, privacy store.Privacy, content string) error {
+	var err error
+	if privacy.SendsToChannel() {
+		_, err = bot.discord.CreateMessage(content)
+	}
+	if privacy.SendsDM() {
+		if _, dmErr := bot.discord.CreateDirectMessage(userId, content); dmErr != nil {
+			err = dmErr
+		}
+	}
+	if err != nil {
+		bot.logger.WithError(err).WithField("userId", userId).Error("could not send message to player")
+	}
+	return err
+}
+
+// Checks if the stats of the user may be shown in reply to the message,
+// replying why not if they may not. Players can always see their own stats
+// unless they opted out; private players' stats are hidden from others, as
+// is the SR of players hiding it if needsSR is set.
+func (bot *Bot) canShowPlayer(message *discordgo.Message, userId string, needsSR bool) bool {
+	privacy := bot.getPrivacy(userId)
+	if userId == message.Author.ID {
+		if privacy.OptedOut {
+			bot.reply(message, "you opted out of tracking, use `!optin` to be tracked again")
+			return false
+		}
+		return true
+	}
+
+	if privacy.IsPrivate() {
+		bot.reply(message, "that player's stats are private")
+		return false
+	}
+	if needsSR && privacy.HideSR() {
+		bot.reply(message, "that player's SR is private")
+		return false
+	}
+	return true
+}
+
+// Removes everything about SR from the session data
+func withoutSR(sessionData playerSessionData) playerSessionData {
+	sessionData.SRHidden = true
+	sessionData.InitialSR = 0
+	sessionData.FinalSR = 0
+	sessionData.SRDiff = 0
+	sessionData.Matches = matchesWithoutSR(sessionData.Matches)
+
+	return sessionData
+}
+
+// Handles the !optout command, which stops tracking the author
+func (bot *Bot) optOutCommand(message *discordgo.Message) {
+	user := message.Author
+	bot.logger.WithField("user", user).Info("opt out request")
+
+	privacy := bot.getPrivacy(user.ID)
+	privacy.OptedOut = true
+	if err := bot.store.PutPrivacy(user.ID, privacy); err != nil {
+		bot.logger.WithError(err).WithField("userId", user.ID).Error("could not store privacy settings")
+		bot.reply(message, "could not opt out, please try again")
+		return
+	}
+	bot.endPlayerSession(user.ID)
+
+	bot.reply(message, user.Username+" is no longer tracked. Your past sessions are kept, use `!forgetme` to delete them, or `!optin` to be tracked again")
+}
+
+// Handles the !optin command, which resumes tracking the author
+func (bot *Bot) optInCommand(message *discordgo.Message) {
+	user := message.Author
+	bot.logger.WithField("user", user).Info("opt in request")
+
+	privacy := bot.getPrivacy(user.ID)
+	privacy.OptedOut = false
+	if err := bot.store.PutPrivacy(user.ID, privacy); err != nil {
+		bot.logger.WithError(err).WithField("userId", user.ID).Error("could not store privacy settings")
+		bot.reply(message, "could not opt in, please try again")
+		return
+	}
+
+	bot.reply(message, user.Username+" is tracked again, "+privacyDescriptions[privacy.Mode])
+}
+
+// Handles the !privacy command, showing or changing how the author's
+// session reports are shared
+func (bot *Bot) privacyCommand(message *discordgo.Message, args string) {
+	user := message.Author
+	bot.logger.WithField("user", user).WithField("args", args).Info("privacy request")
+
+	privacy := bot.getPrivacy(user.ID)
+	mode := strings.ToLower(strings.TrimSpace(args))
+	if mode == "" {
+		content := user.Username + ": " + privacy.Mode + ", " + privacyDescriptions[privacy.Mode]
+		if privacy.OptedOut {
+			content += " once you `!optin` again"
+		}
+		bot.reply(message, content)
+		return
+	}
+	if _, ok := privacyDescriptions[mode]; !ok {
+		bot.reply(message, privacyUsage)
+		return
+	}
+
+	privacy.Mode = mode
+	if err := bot.store.PutPrivacy(user.ID, privacy); err != nil {
+		bot.logger.WithError(err).WithField("userId", user.ID).Error("could not store privacy settings")
+		bot.reply(message, "could not save privacy setting, please try again")
+		return
+	}
+	bot.reply(message, user.Username+": "+privacyDescriptions[mode])
+}
+
+// Handles the !delivery command, showing or changing where the author's
+// session reports are delivered
+func (bot *Bot) deliveryCommand(message *discordgo.Message, args string) {
+	user := message.Author
+	bot.logger.WithField("user", user).WithField("args", args).Info("delivery request")
+
+	privacy := bot.getPrivacy(user.ID)
+	delivery := strings.ToLower(strings.TrimSpace(args))
+	if delivery == "" {
+		content := user.Username + ": " + privacy.Delivery + ", " + deliveryDescriptions[privacy.Delivery]
+		if privacy.Mode == store.PrivacyDM {
+			content += ", but your privacy mode keeps them out of the channel"
+		}
+		bot.reply(message, content)
+		return
+	}
+	if _, ok := deliveryDescriptions[delivery]; !ok {
+		bot.reply(message, deliveryUsage)
+		return
+	}
+
+	privacy.Delivery = delivery
+	if err := bot.store.PutPrivacy(user.ID, privacy); err != nil {
+		bot.logger.WithError(err).WithField("userId", user.ID).Error("could not store privacy settings")
+		bot.reply(message, "could not save delivery setting, please try again")
+		return
+	}
+	bot.reply(message, user.Username+": "+deliveryDescriptions[delivery])
+}
+
+// Handles the !forgetme command, which deletes everything stored about the
+// author: sessions, pending verification, link with alt accounts and
+// privacy settings. Opting out is kept, so that the author is not tracked
+// again if a battleTag file link brings them back.
+func (bot *Bot) forgetMeCommand(message *discordgo.Message) {
+	user := message.Author
+	bot.logger.WithField("user", user).Info("forget request")
+
+	if err := bot.store.DeleteSessions(user.ID); err != nil {
+		bot.logger.WithError(err).WithField("userId", user.ID).Error("could not delete sessions")
+		bot.reply(message, "could not delete your sessions, please try again")
+		return
+	}
+
+	if err := bot.store.DeleteVerification(user.ID); err != nil {
+		bot.logger.WithError(err).WithField("userId", user.ID).Error("could not delete verification")
+		bot.reply(message, "could not delete your pending verification, please try again")
+		return
+	}
+
+	link, err := bot.store.GetLink(user.ID)
+	if err != nil {
+		bot.logger.WithError(err).WithField("userId", user.ID).Error("could not get link")
+		bot.reply(message, "could not delete your link, please try again")
+		return
+	}
+	if link != nil {
+		if err := bot.unlinkPlayer(user.ID); err != nil {
+			bot.logger.WithError(err).WithField("userId", user.ID).Error("could not unlink player")
+			bot.reply(message, "could not delete your link, please try again")
+			return
+		}
+	}
+
+	privacy, err := bot.store.GetPrivacy(user.ID)
+	if err == nil {
+		if privacy.OptedOut {
+			err = bot.store.PutPrivacy(user.ID, store.Privacy{OptedOut: true})
+		} else {
+			err = bot.store.DeletePrivacy(user.ID)
+		}
+	}
+	if err != nil {
+		bot.logger.WithError(err).WithField("userId", user.ID).Error("could not delete privacy settings")
+		bot.reply(message, "could not delete your privacy settings, please try again")
+		return
+	}
+
+	content := "deleted every stored session, link, alt account, pending verification and privacy setting of " + user.Username
+	if privacy.OptedOut {
+		content += ". You stay opted out, so you are not tracked again"
+	}
+	if link != nil && link.Source == store.LinkSourceFile {
+		content += ". Your link comes from the battleTag file and comes back on the next reload, ask an admin to remove it"
+	}
+	bot.reply(message, content)
+}
+
+// Forgets the session the player is in, if any, so that it is not reported
+func (bot *Bot) endPlayerSession(userId string) {
+	lockedPlayerState, ok := bot.getPlayerState(userId)
+	if !ok {
+		return
+	}
+
+	lockedPlayerState.UpdateMutex.Lock()
+	defer lockedPlayerState.UpdateMutex.Unlock()
+
+	playerState, ok := bot.getPlayerState(userId)
+	if !ok || playerState.UpdateMutex != lockedPlayerState.UpdateMutex {
+		return
+	}
+	playerState.Game = nil
+	playerState.Snapshots = nil
+	bot.setPlayerState(userId, playerState)
+}
+
+// Returns the sessions that may be shared in the channel or on the
+// dashboard: sessions of private players are left out, and the SR of
+// players hiding it is removed
+func (bot *Bot) getSharedSessions(records []store.SessionRecord) []store.SessionRecord {
+	privacies := make(map[string]store.Privacy)
+	var shared []store.SessionRecord
+	for _, record := range records {
+		privacy, ok := privacies[record.UserId]
+		if !ok {
+			privacy = bot.getPrivacy(record.UserId)
+			privacies[record.UserId] = privacy
+		}
+
+		if privacy.IsPrivate() {
+			continue
+		}
+		if privacy.HideSR() {
+			record = recordWithoutSR(record)
+		}
+		shared = append(shared, record)
+	}
+
+	return shared
+}
+
+func recordWithoutSR(record store.SessionRecord) store.SessionRecord {
+	record.InitialSR = 0
+	record.FinalSR = 0
+	record.Placement = false
+	record.Matches = matchesWithoutSR(record.Matches)
+
+	return record
+}
+
+func matchesWithoutSR(matches []overwatch.Match) []overwatch.Match {
+	var hidden []overwatch.Match
+	for _, match := range matches {
+		match.SRDiff = 0
+		match.SRKnown = false
+		hidden = append(hidden, match)
+	}
+	return hidden
+}
@@ -0,0 +1,63 @@
+package owbot
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/snakelayer/discord-oversessions/owbot/overwatch"
+)
+
+const rankUsage = "usage: `!rank [@user]`"
+
+// Handles the !rank command, posting an embed with the rank tier of the
+// author or the mentioned user and their progress to the next tier
+func (bot *Bot) rankCommand(message *discordgo.Message, args string) {
+	bot.logger.WithField("user", message.Author).WithField("args", args).Info("rank request")
+
+	userId := message.Author.ID
+	if args = strings.TrimSpace(args); args != "" {
+		match := regexUserMention.FindStringSubmatch(args)
+		if match == nil {
+			bot.reply(message, rankUsage)
+			return
+		}
+		userId = match[1]
+	}
+
+	playerState, ok := bot.getPlayerState(userId)
+	if !ok {
+		bot.reply(message, "that user is not linked to a battleTag")
+		return
+	}
+	if !bot.canShowPlayer(message, userId, true) {
+		return
+	}
+	if playerState.RegionBlob == nil {
+		bot.reply(message, "no stats known for "+playerState.BattleTag+" yet")
+		return
+	}
+
+	name := bot.getPlayerNames()[userId]
+	sr := playerState.RegionBlob.GetCompRank()
+	progress, ok := overwatch.GetTierProgress(sr)
+	if !ok {
+		bot.reply(message, fmt.Sprintf("**%s** is unranked this season", name))
+		return
+	}
+
+	description := fmt.Sprintf("**%d SR**", sr)
+	if progress.SRToNext != 0 {
+		description += fmt.Sprintf("\n%d SR to %s (%d%% of the way)", progress.SRToNext, progress.Next.Name, progress.Percent)
+	}
+	embed := &discordgo.MessageEmbed{
+		Title:       name + ": " + progress.Tier.Name,
+		Description: description,
+		Color:       progress.Tier.Color,
+		Thumbnail:   &discordgo.MessageEmbedThumbnail{URL: progress.Tier.Icon},
+		Footer:      &discordgo.MessageEmbedFooter{Text: playerState.BattleTag},
+	}
+	if _, err := bot.replyEmbed(message, embed); err != nil {
+		bot.logger.WithError(err).Error("could not send rank embed")
+	}
+}
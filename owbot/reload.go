@@ -0,0 +1,103 @@
+package owbot
+
+import (
+	"github.com/snakelayer/discord-oversessions/owbot/config"
+)
+
+// Applies a reloaded config. Settings that can only be used on startup
+// (token, dbFile) are kept as they are, with a warning if they changed.
+func (bot *Bot) UpdateConfig(nextConfig *config.Config) {
+	prevConfig := bot.getConfig()
+
+	if nextConfig.Token != prevConfig.Token {
+		bot.logger.Warn("token changed, restart the bot to apply it")
+		nextConfig.Token = prevConfig.Token
+	}
+	if nextConfig.DBFile != prevConfig.DBFile {
+		bot.logger.WithField("dbFile", nextConfig.DBFile).Warn("dbFile changed, restart the bot to apply it")
+		nextConfig.DBFile = prevConfig.DBFile
+	}
+
+	if nextConfig.HTTP != prevConfig.HTTP {
+		bot.logger.WithField("listen", nextConfig.HTTP.Listen).Warn("http settings changed, restart the bot to apply them")
+		nextConfig.HTTP = prevConfig.HTTP
+	}
+
+	if nextConfig.Overwatch.BaseUrl != prevConfig.Overwatch.BaseUrl {
+		if err := bot.overwatch.SetBaseUrl(nextConfig.Overwatch.BaseUrl); err != nil {
+			bot.logger.WithError(err).Error("could not apply overwatch base url")
+			nextConfig.Overwatch.BaseUrl = prevConfig.Overwatch.BaseUrl
+		}
+	}
+
+	if nextConfig.Guild.ChannelPattern != prevConfig.Guild.ChannelPattern {
+		if err := bot.discord.SetChannelPattern(nextConfig.Guild.ChannelPattern); err != nil {
+			bot.logger.WithError(err).Error("could not apply channel pattern")
+			nextConfig.Guild.ChannelPattern = prevConfig.Guild.ChannelPattern
+		}
+	}
+
+	templateTexts, err := loadTemplateFiles(nextConfig.Guild.TemplateFiles)
+	if err != nil {
+		bot.logger.WithError(err).Error("could not load template files, keeping the current templates")
+		nextConfig.Guild.TemplateFiles = prevConfig.Guild.TemplateFiles
+	}
+
+	bot.mutex.Lock()
+	bot.config = nextConfig
+	if templateTexts != nil {
+		bot.templateTexts = templateTexts
+	}
+	bot.mutex.Unlock()
+
+	bot.logger.Info("applied reloaded config")
+}
+
+// Applies a reloaded battleTag map to the stored links and player states.
+// Links from the battleTag file that are missing from the map are removed,
+// new links are added and changed links are retagged. Links created by
+// commands or the admin api are kept, unless the map links the same user.
+// A retagged player that is currently playing keeps their session, which
+// is reported against the new battleTag.
+//
+// The stored links are updated right away. Player states are updated in the
+// background, as a change to a player waits for any in-flight presence
+// update or session report of that player to finish.
+func (bot *Bot) UpdateBattleTags(battleTagMap map[string]string) {
+	changed, removed, err := syncFileLinks(bot.store, battleTagMap)
+	if err != nil {
+		bot.logger.WithError(err).Error("could not update stored links")
+		return
+	}
+
+	userIds := removed
+	for userId := range changed {
+		userIds = append(userIds, userId)
+	}
+	go func() {
+		for _, userId := range userIds {
+			bot.syncPlayer(userId)
+		}
+	}()
+}
+
+// Updates the player state of a user to match their stored link. The link is
+// read again rather than passed in, so that player states end up matching
+// the latest links when reloads overlap.
+func (bot *Bot) syncPlayer(userId string) {
+	link, err := bot.store.GetLink(userId)
+	if err != nil {
+		bot.logger.WithError(err).WithField("userId", userId).Error("could not get link")
+		return
+	}
+
+	playerState, ok := bot.getPlayerState(userId)
+	switch {
+	case link == nil:
+		bot.removePlayer(userId)
+	case !ok:
+		bot.addPlayer(userId, link.BattleTag)
+	case playerState.BattleTag != link.BattleTag:
+		bot.retagPlayer(userId, link.BattleTag)
+	}
+}
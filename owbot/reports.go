@@ -0,0 +1,117 @@
+package owbot
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/snakelayer/discord-oversessions/owbot/metrics"
+	"github.com/snakelayer/discord-oversessions/owbot/player"
+)
+
+// A session report waiting for the player's stats to update
+type pendingReport struct {
+	UserId    string    `json:"userId"`
+	Username  string    `json:"username"`
+	BattleTag string    `json:"battleTag"`
+	Started   time.Time `json:"started"`
+	Attempt   int       `json:"attempt"`
+}
+
+func (bot *Bot) addPendingReport(playerState *player.PlayerState) {
+	bot.reportsMutex.Lock()
+	defer bot.reportsMutex.Unlock()
+
+	bot.pendingReports[playerState.User.ID] = &pendingReport{
+		UserId:    playerState.User.ID,
+		Username:  playerState.User.Username,
+		BattleTag: playerState.BattleTag,
+		Started:   time.Now(),
+	}
+	metrics.PendingReports.Set(float64(len(bot.pendingReports)))
+}
+
+func (bot *Bot) setPendingReportAttempt(userId string, attempt int) {
+	bot.reportsMutex.Lock()
+	defer bot.reportsMutex.Unlock()
+
+	if report, ok := bot.pendingReports[userId]; ok {
+		report.Attempt = attempt
+	}
+}
+
+func (bot *Bot) removePendingReport(userId string) {
+	bot.reportsMutex.Lock()
+	defer bot.reportsMutex.Unlock()
+
+	delete(bot.pendingReports, userId)
+	metrics.PendingReports.Set(float64(len(bot.pendingReports)))
+}
+
+// Returns copies of the pending reports, oldest first
+func (bot *Bot) getPendingReports() []pendingReport {
+	bot.reportsMutex.Lock()
+	defer bot.reportsMutex.Unlock()
+
+	reports := make([]pendingReport, 0, len(bot.pendingReports))
+	for _, report := range bot.pendingReports {
+		reports = append(reports, *report)
+	}
+	sort.Slice(reports, func(i, j int) bool {
+		return reports[i].Started.Before(reports[j].Started)
+	})
+
+	return reports
+}
+
+// Reports the player's stats now, without waiting for the session to end.
+// The report covers the changes since the player's stats were last fetched,
+// and shows the current stats if nothing changed. The player state is not
+// modified, so a session in progress is still reported when it ends.
+func (bot *Bot) forceSessionReport(userId string) error {
+	lockedPlayerState, ok := bot.getPlayerState(userId)
+	if !ok {
+		return errors.New("player is not linked")
+	}
+
+	lockedPlayerState.UpdateMutex.Lock()
+	defer lockedPlayerState.UpdateMutex.Unlock()
+
+	prev, ok := bot.getPlayerState(userId)
+	if !ok {
+		return errors.New("player is not linked")
+	}
+	if prev.User == nil {
+		if err := bot.discord.SetUser(userId, &prev); err != nil {
+			return err
+		}
+	}
+
+	privacy := bot.getPrivacy(userId)
+	if privacy.OptedOut {
+		return errors.New("player opted out of tracking")
+	}
+
+	next := prev
+	next.Timestamp = time.Now()
+	if err := bot.setPlayerBlob(&next); err != nil {
+		return err
+	}
+	if next.RegionBlob == nil {
+		return errors.New("no stats found for " + next.BattleTag)
+	}
+
+	messageContent := bot.getSessionReportMessage(&prev, &next, privacy)
+	if messageContent == "" && privacy.HideSR() {
+		return errors.New("nothing to report without the player's SR")
+	}
+	if messageContent == "" {
+		messageContent = bot.getTemplateMessage(bot.getTemplate(noChangeTemplateName), bot.getNoChangeSessionData(&next))
+	}
+
+	if err := bot.sendToPlayer(userId, privacy, messageContent); err != nil {
+		return err
+	}
+	metrics.SessionsReported.Inc()
+	return nil
+}
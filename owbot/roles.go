@@ -0,0 +1,154 @@
+package owbot
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/snakelayer/discord-oversessions/owbot/overwatch"
+	"github.com/snakelayer/discord-oversessions/owbot/player"
+)
+
+const statsUsage = "usage: `!stats [@user]`"
+
+// The comp results of the heroes of one role
+type roleResult struct {
+	Role overwatch.Role
+	WDL  overwatch.WDL
+	// Sum of the SR changes of the matches with a known SR change that the
+	// role was played in
+	SRDiff  int
+	SRKnown bool
+}
+
+func (result roleResult) Games() int {
+	return result.WDL.Win + result.WDL.Draw + result.WDL.Loss
+}
+
+// Sums the results of the heroes by role, in the order of overwatch.Roles.
+// Roles without games are left out. A match played on heroes of several
+// roles counts for each of them.
+func getRoleResults(heroesWDL map[string]overwatch.WDL, matches []overwatch.Match) []roleResult {
+	results := make(map[overwatch.Role]*roleResult)
+	result := func(heroId string) *roleResult {
+		hero, ok := overwatch.GetHero(heroId)
+		if !ok {
+			return nil
+		}
+		if _, ok := results[hero.Role]; !ok {
+			results[hero.Role] = &roleResult{Role: hero.Role}
+		}
+		return results[hero.Role]
+	}
+
+	for heroId, wdl := range heroesWDL {
+		if r := result(heroId); r != nil {
+			r.WDL.Win += wdl.Win
+			r.WDL.Draw += wdl.Draw
+			r.WDL.Loss += wdl.Loss
+		}
+	}
+
+	for _, match := range matches {
+		if !match.SRKnown {
+			continue
+		}
+		counted := make(map[*roleResult]bool)
+		for _, heroId := range match.Heroes {
+			if r := result(heroId); r != nil && !counted[r] {
+				r.SRDiff += match.SRDiff
+				r.SRKnown = true
+				counted[r] = true
+			}
+		}
+	}
+
+	var sortedResults []roleResult
+	for _, role := range overwatch.Roles {
+		if r, ok := results[role]; ok && r.Games() > 0 {
+			sortedResults = append(sortedResults, *r)
+		}
+	}
+
+	return sortedResults
+}
+
+// The comp results of the season by role, from the games played on each hero
+func getSeasonRoleResults(allHeroStats *overwatch.AllHeroStats) []roleResult {
+	heroesWDL := make(map[string]overwatch.WDL)
+	for _, hero := range overwatch.Heroes {
+		if heroStats := allHeroStats.HeroStats(hero.Id); heroStats != nil {
+			heroesWDL[hero.Id] = overwatch.MakeWDL(&overwatch.HeroStruct{}, heroStats)
+		}
+	}
+
+	return getRoleResults(heroesWDL, nil)
+}
+
+// Handles the !stats command, showing how the comp games of every linked
+// player, or of the mentioned user, are split over the roles this season
+func (bot *Bot) statsCommand(message *discordgo.Message, args string) {
+	bot.logger.WithField("user", message.Author).WithField("args", args).Info("stats request")
+
+	playerStates := bot.getPlayerStates()
+	if args = strings.TrimSpace(args); args != "" {
+		match := regexUserMention.FindStringSubmatch(args)
+		if match == nil {
+			bot.reply(message, statsUsage)
+			return
+		}
+		playerState, ok := playerStates[match[1]]
+		if !ok {
+			bot.reply(message, "that user is not linked to a battleTag")
+			return
+		}
+		if !bot.canShowPlayer(message, match[1], false) {
+			return
+		}
+		playerStates = map[string]player.PlayerState{match[1]: playerState}
+	}
+
+	names := bot.getPlayerNames()
+	var lines []string
+	for userId, playerState := range playerStates {
+		if playerState.RegionBlob == nil {
+			continue
+		}
+		if len(playerStates) > 1 && bot.getPrivacy(userId).IsPrivate() {
+			continue
+		}
+		results := getSeasonRoleResults(playerState.RegionBlob.GetAllHeroStats())
+		if len(results) == 0 {
+			continue
+		}
+		lines = append(lines, getRoleDistributionLine(names[userId], results))
+	}
+	if len(lines) == 0 {
+		bot.reply(message, "no comp games known this season")
+		return
+	}
+	sort.Strings(lines)
+
+	var buffer bytes.Buffer
+	buffer.WriteString("comp games by role this season:")
+	for _, line := range lines {
+		buffer.WriteString("\n" + line)
+	}
+	bot.reply(message, buffer.String())
+}
+
+// getRoleDistributionLine => "**Name**: tank 40% (12-1-7), support 60% (17-0-13)"
+func getRoleDistributionLine(name string, results []roleResult) string {
+	total := 0
+	for _, result := range results {
+		total += result.Games()
+	}
+
+	var roles []string
+	for _, result := range results {
+		roles = append(roles, fmt.Sprintf("%s %d%% (%d-%d-%d)", result.Role, result.Games()*100/total, result.WDL.Win, result.WDL.Draw, result.WDL.Loss))
+	}
+	return fmt.Sprintf("**%s**: %s", name, strings.Join(roles, ", "))
+}
@@ -0,0 +1,247 @@
+package owbot
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/snakelayer/discord-oversessions/owbot/overwatch"
+	"github.com/snakelayer/discord-oversessions/owbot/store"
+)
+
+// Returns the season of a session starting at start. Configured seasons
+// take precedence; otherwise the session is in the current season, unless
+// the player's comp stats reset during it and the player has no sessions
+// in the current season yet, in which case it is the first session of the
+// next season.
+func (bot *Bot) getSessionSeason(userId string, start time.Time, newSeason bool) int {
+	if number, ok := bot.getConfig().SeasonAt(start); ok {
+		return number
+	}
+
+	current, err := bot.store.GetCurrentSeason()
+	if err != nil {
+		bot.logger.WithError(err).Error("could not get current season")
+	}
+	if current.Number == 0 {
+		return 1
+	}
+	if !newSeason {
+		return current.Number
+	}
+
+	// other players may already have started the new season
+	last, ok, err := bot.store.GetLastSession(userId)
+	if err != nil {
+		bot.logger.WithError(err).WithField("userId", userId).Error("could not get last session")
+		return current.Number
+	}
+	if ok && last.Season < current.Number {
+		return current.Number
+	}
+	return current.Number + 1
+}
+
+// Makes number the current season if it is later than the current one,
+// and posts the recaps of the season that ended
+func (bot *Bot) advanceSeason(number int, start time.Time) {
+	current, err := bot.store.GetCurrentSeason()
+	if err != nil {
+		bot.logger.WithError(err).Error("could not get current season")
+		return
+	}
+	if number <= current.Number {
+		return
+	}
+
+	if err := bot.store.PutCurrentSeason(store.Season{Number: number, Start: start}); err != nil {
+		bot.logger.WithError(err).Error("could not store current season")
+		return
+	}
+	bot.logger.WithField("season", number).Info("new season started")
+
+	if current.Number != 0 {
+		bot.postSeasonRecaps(current.Number)
+	}
+}
+
+// The sessions of one player in a season
+type seasonRecap struct {
+	UserId   string
+	Name     string
+	Sessions int
+	CompWDL  overwatch.WDL
+
+	// The player hides their SR, so the SR fields are zero
+	SRHidden bool
+	// SR after the placement matches, if they were played in a stored session
+	PlacementSR int
+	StartSR     int
+	PeakSR      int
+	FinalSR     int
+}
+
+// Summarizes the sessions of each player in the season, by user id
+func (bot *Bot) getSeasonRecaps(season int) (map[string]*seasonRecap, error) {
+	records, err := bot.store.GetAllSessions(time.Time{})
+	if err != nil {
+		return nil, err
+	}
+
+	names := bot.getPlayerNames()
+	recaps := make(map[string]*seasonRecap)
+	for _, record := range records {
+		if record.Season != season {
+			continue
+		}
+
+		recap, ok := recaps[record.UserId]
+		if !ok {
+			name, ok := names[record.UserId]
+			if !ok {
+				name = record.BattleTag
+			}
+			recap = &seasonRecap{UserId: record.UserId, Name: name}
+			recaps[record.UserId] = recap
+		}
+
+		compWDL := record.CompWDL()
+		recap.Sessions++
+		recap.CompWDL.Win += compWDL.Win
+		recap.CompWDL.Draw += compWDL.Draw
+		recap.CompWDL.Loss += compWDL.Loss
+
+		// the SR of the season is the SR of the primary account
+		if record.Alt {
+			continue
+		}
+		if record.Placement && record.FinalSR != 0 && recap.PlacementSR == 0 {
+			recap.PlacementSR = record.FinalSR
+		}
+		for _, sr := range []int{record.InitialSR, record.FinalSR} {
+			if sr == 0 {
+				continue
+			}
+			if recap.StartSR == 0 {
+				recap.StartSR = sr
+			}
+			if sr > recap.PeakSR {
+				recap.PeakSR = sr
+			}
+			recap.FinalSR = sr
+		}
+	}
+
+	return recaps, nil
+}
+
+func (bot *Bot) postSeasonRecaps(season int) {
+	recaps, err := bot.getSeasonRecaps(season)
+	if err != nil {
+		bot.logger.WithError(err).WithField("season", season).Error("could not get season recaps")
+		return
+	}
+
+	var sortedRecaps []*seasonRecap
+	for _, recap := range recaps {
+		sortedRecaps = append(sortedRecaps, recap)
+	}
+	sort.Slice(sortedRecaps, func(i, j int) bool {
+		if sortedRecaps[i].FinalSR != sortedRecaps[j].FinalSR {
+			return sortedRecaps[i].FinalSR > sortedRecaps[j].FinalSR
+		}
+		return sortedRecaps[i].Name < sortedRecaps[j].Name
+	})
+
+	for _, recap := range sortedRecaps {
+		bot.postSeasonRecap(season, recap)
+	}
+	bot.logger.WithField("season", season).WithField("players", len(sortedRecaps)).Info("posted season recaps")
+}
+
+// Posts the recap as the player chose to share their reports. Opted out
+// players get no recap.
+func (bot *Bot) postSeasonRecap(season int, recap *seasonRecap) {
+	privacy := bot.getPrivacy(recap.UserId)
+	if privacy.OptedOut {
+		return
+	}
+	if privacy.HideSR() {
+		hidden := seasonRecap{UserId: recap.UserId, Name: recap.Name, Sessions: recap.Sessions, CompWDL: recap.CompWDL, SRHidden: true}
+		recap = &hidden
+	}
+
+	bot.sendToPlayer(recap.UserId, privacy, getSeasonRecapMessage(season, recap))
+}
+
+func getSeasonRecapMessage(season int, recap *seasonRecap) string {
+	var buffer bytes.Buffer
+	fmt.Fprintf(&buffer, "**Season %d recap: %s**\n", season, recap.Name)
+
+	if recap.PlacementSR != 0 {
+		fmt.Fprintf(&buffer, "placed at %d SR\n", recap.PlacementSR)
+	}
+	if recap.SRHidden {
+		buffer.WriteString("SR: hidden\n")
+	} else if tier, ok := overwatch.GetTier(recap.FinalSR); ok {
+		fmt.Fprintf(&buffer, "SR: started at %d, peaked at %d, finished at %d (%+d) in %s\n", recap.StartSR, recap.PeakSR, recap.FinalSR, recap.FinalSR-recap.StartSR, tier.Name)
+	} else {
+		buffer.WriteString("SR: unranked\n")
+	}
+
+	fmt.Fprintf(&buffer, "comp: %d W / %d D / %d L", recap.CompWDL.Win, recap.CompWDL.Draw, recap.CompWDL.Loss)
+	if !recap.CompWDL.IsEmpty() {
+		fmt.Fprintf(&buffer, " (%d%% won)", winRate(recap.CompWDL))
+	}
+	fmt.Fprintf(&buffer, " over %d %s", recap.Sessions, plural(recap.Sessions, "session", "sessions"))
+
+	return buffer.String()
+}
+
+const seasonUsage = "usage: `!season [number]`"
+
+// Handles the !season command, showing the author's recap of the current
+// or the given season so far
+func (bot *Bot) seasonCommand(message *discordgo.Message, args string) {
+	bot.logger.WithField("user", message.Author).WithField("args", args).Info("season request")
+
+	current, err := bot.store.GetCurrentSeason()
+	if err != nil {
+		bot.logger.WithError(err).Error("could not get current season")
+		return
+	}
+
+	season := current.Number
+	if args = strings.TrimSpace(args); args != "" {
+		if season, err = strconv.Atoi(args); err != nil || season < 1 {
+			bot.reply(message, seasonUsage)
+			return
+		}
+	}
+	if season == 0 {
+		bot.reply(message, "no sessions have been recorded yet")
+		return
+	}
+
+	recaps, err := bot.getSeasonRecaps(season)
+	if err != nil {
+		bot.logger.WithError(err).WithField("season", season).Error("could not get season recaps")
+		return
+	}
+
+	recap, ok := recaps[message.Author.ID]
+	if !ok {
+		bot.reply(message, fmt.Sprintf("%s has no sessions in season %d", message.Author.Username, season))
+		return
+	}
+	if bot.discord.IsDirectMessageChannel(message.ChannelID) {
+		// asked privately, so the player sees their own recap in full
+		bot.reply(message, getSeasonRecapMessage(season, recap))
+		return
+	}
+	bot.postSeasonRecap(season, recap)
+}
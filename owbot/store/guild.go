@@ -0,0 +1,39 @@
+package store
+
+const guildBucket = "guilds"
+
+// GuildSettings holds the per guild customizations made by guild admins.
+type GuildSettings struct {
+	// Report templates by name, overriding the defaults
+	Templates map[string]string `json:"templates,omitempty"`
+	// Emoji by hero id, overriding the auto-discovered guild emoji
+	Emojis map[string]string `json:"emojis,omitempty"`
+	// Whether each kind of announcement is posted, by kind. Kinds not
+	// listed are posted
+	Announcements map[string]bool `json:"announcements,omitempty"`
+	// The channel picked with !setchannel, overriding the channel pattern
+	ChannelId string `json:"channelId,omitempty"`
+}
+
+// Returns the settings of a guild, or empty settings if none are stored.
+func (store *Store) GetGuildSettings(guildId string) (*GuildSettings, error) {
+	settings := &GuildSettings{}
+	if _, err := store.get(guildBucket, guildId, settings); err != nil {
+		return nil, err
+	}
+
+	if settings.Templates == nil {
+		settings.Templates = make(map[string]string)
+	}
+	if settings.Emojis == nil {
+		settings.Emojis = make(map[string]string)
+	}
+	if settings.Announcements == nil {
+		settings.Announcements = make(map[string]bool)
+	}
+	return settings, nil
+}
+
+func (store *Store) PutGuildSettings(guildId string, settings *GuildSettings) error {
+	return store.put(guildBucket, guildId, settings)
+}
@@ -0,0 +1,60 @@
+package store
+
+import (
+	"encoding/json"
+)
+
+const linkBucket = "links"
+
+// Where a link was created
+const (
+	LinkSourceFile    = "file"
+	LinkSourceCommand = "command"
+	LinkSourceAPI     = "api"
+)
+
+// Link is a Discord user linked to a battleTag, their primary account, and
+// to any number of alt accounts
+type Link struct {
+	UserId    string   `json:"userId"`
+	BattleTag string   `json:"battleTag"`
+	Source    string   `json:"source"`
+	Alts      []string `json:"alts,omitempty"`
+}
+
+// Returns all links by userId
+func (store *Store) GetLinks() (map[string]Link, error) {
+	links := make(map[string]Link)
+	err := store.forEach(linkBucket, func(userId string, data []byte) error {
+		var link Link
+		if err := json.Unmarshal(data, &link); err != nil {
+			return err
+		}
+		links[userId] = link
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return links, nil
+}
+
+// Returns the link of the user, or nil if the user is not linked
+func (store *Store) GetLink(userId string) (*Link, error) {
+	link := &Link{}
+	ok, err := store.get(linkBucket, userId, link)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	return link, nil
+}
+
+func (store *Store) PutLink(link Link) error {
+	return store.put(linkBucket, link.UserId, link)
+}
+
+func (store *Store) DeleteLink(userId string) error {
+	return store.delete(linkBucket, userId)
+}
@@ -0,0 +1,74 @@
+package store
+
+const privacyBucket = "privacy"
+
+// How a user's session reports are shared
+const (
+	// Reports are posted in the channel
+	PrivacyPublic = "public"
+	// Reports are sent to the user by direct message, and the user is left
+	// out of everything posted in the channel
+	PrivacyDM = "dm"
+	// Reports are posted in the channel without SR
+	PrivacyHideSR = "hidesr"
+)
+
+// Where a user's session reports are delivered, unless their privacy mode
+// keeps them out of the channel
+const (
+	DeliveryChannel = "channel"
+	DeliveryDM      = "dm"
+	DeliveryBoth    = "both"
+)
+
+// Privacy holds the choices a user made about being tracked
+type Privacy struct {
+	// Opted out users are not tracked at all
+	OptedOut bool   `json:"optedOut,omitempty"`
+	Mode     string `json:"mode,omitempty"`
+	Delivery string `json:"delivery,omitempty"`
+}
+
+// Whether the user is left out of everything posted in the channel
+func (privacy Privacy) IsPrivate() bool {
+	return privacy.OptedOut || privacy.Mode == PrivacyDM
+}
+
+func (privacy Privacy) HideSR() bool {
+	return privacy.Mode == PrivacyHideSR
+}
+
+// Whether the user's reports are posted in the channel
+func (privacy Privacy) SendsToChannel() bool {
+	return !privacy.IsPrivate() && privacy.Delivery != DeliveryDM
+}
+
+// Whether the user's reports are sent to them by direct message
+func (privacy Privacy) SendsDM() bool {
+	if privacy.OptedOut {
+		return false
+	}
+	return privacy.Mode == PrivacyDM || privacy.Delivery == DeliveryDM || privacy.Delivery == DeliveryBoth
+}
+
+// Returns the privacy settings of the user, which default to public reports
+// delivered to the channel
+func (store *Store) GetPrivacy(userId string) (Privacy, error) {
+	var privacy Privacy
+	_, err := store.get(privacyBucket, userId, &privacy)
+	if privacy.Mode == "" {
+		privacy.Mode = PrivacyPublic
+	}
+	if privacy.Delivery == "" {
+		privacy.Delivery = DeliveryChannel
+	}
+	return privacy, err
+}
+
+func (store *Store) PutPrivacy(userId string, privacy Privacy) error {
+	return store.put(privacyBucket, userId, privacy)
+}
+
+func (store *Store) DeletePrivacy(userId string) error {
+	return store.delete(privacyBucket, userId)
+}
@@ -0,0 +1,26 @@
+package store
+
+import "time"
+
+const (
+	seasonBucket     = "seasons"
+	currentSeasonKey = "current"
+)
+
+// Season is a comp season, numbered by the bot or by the config
+type Season struct {
+	Number int       `json:"number"`
+	Start  time.Time `json:"start"`
+}
+
+// Returns the latest season sessions were stored in, or a zero Season if
+// no season is known yet
+func (store *Store) GetCurrentSeason() (Season, error) {
+	var season Season
+	_, err := store.get(seasonBucket, currentSeasonKey, &season)
+	return season, err
+}
+
+func (store *Store) PutCurrentSeason(season Season) error {
+	return store.put(seasonBucket, currentSeasonKey, season)
+}
@@ -0,0 +1,137 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/snakelayer/discord-oversessions/owbot/overwatch"
+)
+
+const sessionBucket = "sessions"
+
+// SessionRecord is a finished session of a player, with the changes in
+// their stats from its start to its end
+type SessionRecord struct {
+	UserId    string    `json:"userId"`
+	BattleTag string    `json:"battleTag"`
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+
+	InitialSR int `json:"initialSR"`
+	FinalSR   int `json:"finalSR"`
+
+	HeroesWDL    map[string]overwatch.WDL `json:"heroesWDL"`
+	QuickplayWDL overwatch.WDL            `json:"quickplayWDL"`
+	// Quickplay results by hero, without draws and with losses only if
+	// they are tracked for the hero
+	QuickplayHeroesWDL map[string]overwatch.WDL `json:"quickplayHeroesWDL,omitempty"`
+
+	// The comp season the session was played in, and whether it included
+	// placement matches, during which the player has no SR
+	Season    int  `json:"season,omitempty"`
+	Placement bool `json:"placement,omitempty"`
+
+	// Comp game stats of the session, if comp games were played
+	Performance *overwatch.GameStatsDiff `json:"performance,omitempty"`
+
+	// Comp matches of the session, in the order they were played
+	Matches []overwatch.Match `json:"matches,omitempty"`
+
+	// The session was played on an alt account of the user, BattleTag. Its
+	// SR is left out of the user's SR history.
+	Alt bool `json:"alt,omitempty"`
+}
+
+// The comp results of the session, summed over all heroes
+func (record SessionRecord) CompWDL() overwatch.WDL {
+	var compWDL overwatch.WDL
+	for _, wdl := range record.HeroesWDL {
+		compWDL.Win += wdl.Win
+		compWDL.Draw += wdl.Draw
+		compWDL.Loss += wdl.Loss
+	}
+
+	return compWDL
+}
+
+func (record SessionRecord) SRDiff() int {
+	return record.FinalSR - record.InitialSR
+}
+
+func (record SessionRecord) Duration() time.Duration {
+	return record.End.Sub(record.Start)
+}
+
+// Sessions are keyed by user and start time, so that the sessions of a user
+// are stored together in chronological order
+func sessionKey(userId string, start time.Time) string {
+	return fmt.Sprintf("%s/%020d", userId, start.UnixNano())
+}
+
+func (store *Store) PutSession(record SessionRecord) error {
+	key := sessionKey(record.UserId, record.Start)
+	if record.Alt {
+		// several accounts can be reported for the same session
+		key += "/" + record.BattleTag
+	}
+	return store.put(sessionBucket, key, record)
+}
+
+// Returns the sessions of the user that ended after since, oldest first
+func (store *Store) GetSessions(userId string, since time.Time) ([]SessionRecord, error) {
+	return store.getSessions(userId+"/", since)
+}
+
+// Returns the sessions of all users that ended after since, oldest first
+func (store *Store) GetAllSessions(since time.Time) ([]SessionRecord, error) {
+	records, err := store.getSessions("", since)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Start.Before(records[j].Start)
+	})
+	return records, nil
+}
+
+// Returns the most recent session of the user, if there is one
+func (store *Store) GetLastSession(userId string) (SessionRecord, bool, error) {
+	var data []byte
+	err := store.forEachPrefix(sessionBucket, userId+"/", func(key string, value []byte) error {
+		data = value
+		return nil
+	})
+	if err != nil || data == nil {
+		return SessionRecord{}, false, err
+	}
+
+	var record SessionRecord
+	return record, true, json.Unmarshal(data, &record)
+}
+
+func (store *Store) getSessions(prefix string, since time.Time) ([]SessionRecord, error) {
+	var records []SessionRecord
+	err := store.forEachPrefix(sessionBucket, prefix, func(key string, data []byte) error {
+		var record SessionRecord
+		if err := json.Unmarshal(data, &record); err != nil {
+			return err
+		}
+		if record.End.After(since) {
+			records = append(records, record)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+// Deletes every stored session of the user
+func (store *Store) DeleteSessions(userId string) error {
+	return store.deletePrefix(sessionBucket, userId+"/")
+}
@@ -0,0 +1,135 @@
+package store
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/boltdb/bolt"
+)
+
+// Store persists bot data in a bolt database. Values are stored as JSON,
+// one bucket per kind of data.
+type Store struct {
+	logger *logrus.Entry
+	db     *bolt.DB
+}
+
+// Opens (creating if needed) the bolt database at path.
+func Open(logger *logrus.Logger, path string) (*Store, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	store := &Store{
+		logger: logger.WithField("module", "store"),
+		db:     db,
+	}
+	store.logger.WithField("path", path).Debug("opened database")
+
+	return store, nil
+}
+
+func (store *Store) Close() error {
+	return store.db.Close()
+}
+
+// Decodes the value stored under key in bucket into v. Returns false if
+// there is no such value.
+func (store *Store) get(bucket string, key string, v interface{}) (bool, error) {
+	var data []byte
+	err := store.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+		// the slice is only valid during the transaction
+		if value := b.Get([]byte(key)); value != nil {
+			data = append([]byte(nil), value...)
+		}
+		return nil
+	})
+	if err != nil || data == nil {
+		return false, err
+	}
+
+	return true, json.Unmarshal(data, v)
+}
+
+// Encodes v as JSON and stores it under key in bucket.
+func (store *Store) put(bucket string, key string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return store.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(bucket))
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(key), data)
+	})
+}
+
+func (store *Store) delete(bucket string, key string) error {
+	return store.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+		return b.Delete([]byte(key))
+	})
+}
+
+// Calls fn with the key and raw JSON value of every entry in bucket
+func (store *Store) forEach(bucket string, fn func(key string, data []byte) error) error {
+	return store.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			return fn(string(k), v)
+		})
+	})
+}
+
+// Calls fn with the key and raw JSON value of every entry in bucket whose
+// key starts with prefix, in key order
+func (store *Store) forEachPrefix(bucket string, prefix string, fn func(key string, data []byte) error) error {
+	return store.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+
+		c := b.Cursor()
+		for k, v := c.Seek([]byte(prefix)); k != nil && strings.HasPrefix(string(k), prefix); k, v = c.Next() {
+			if err := fn(string(k), v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Deletes every entry in bucket whose key starts with prefix
+func (store *Store) deletePrefix(bucket string, prefix string) error {
+	return store.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+
+		c := b.Cursor()
+		for k, _ := c.Seek([]byte(prefix)); k != nil && strings.HasPrefix(string(k), prefix); k, _ = c.Seek([]byte(prefix)) {
+			if err := c.Delete(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,69 @@
+package store
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const verificationBucket = "verifications"
+
+// Verification is a link waiting for the user to prove they own the
+// battleTag, by playing a game on the given hero before it expires
+type Verification struct {
+	UserId    string `json:"userId"`
+	BattleTag string `json:"battleTag"`
+	HeroId    string `json:"heroId"`
+	// Hours played on the hero when the verification started
+	TimePlayed float32   `json:"timePlayed"`
+	Expires    time.Time `json:"expires"`
+	// The battleTag is added as an alt account instead of being linked
+	Alt bool `json:"alt,omitempty"`
+}
+
+func (verification Verification) IsExpired(now time.Time) bool {
+	return !now.Before(verification.Expires)
+}
+
+// Returns the pending verification of the user, or nil if there is none
+func (store *Store) GetVerification(userId string) (*Verification, error) {
+	verification := &Verification{}
+	ok, err := store.get(verificationBucket, userId, verification)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	return verification, nil
+}
+
+func (store *Store) PutVerification(verification Verification) error {
+	return store.put(verificationBucket, verification.UserId, verification)
+}
+
+func (store *Store) DeleteVerification(userId string) error {
+	return store.delete(verificationBucket, userId)
+}
+
+// Deletes the verifications that expired before now, returning how many
+func (store *Store) DeleteExpiredVerifications(now time.Time) (int, error) {
+	var expired []string
+	err := store.forEach(verificationBucket, func(userId string, data []byte) error {
+		var verification Verification
+		if err := json.Unmarshal(data, &verification); err != nil {
+			return err
+		}
+		if verification.IsExpired(now) {
+			expired = append(expired, userId)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, userId := range expired {
+		if err := store.delete(verificationBucket, userId); err != nil {
+			return 0, err
+		}
+	}
+	return len(expired), nil
+}
@@ -0,0 +1,407 @@
+package owbot
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/snakelayer/discord-oversessions/owbot/overwatch"
+)
+
+const (
+	sessionTemplateName  = "session"
+	noChangeTemplateName = "nochange"
+
+	// Largest template accepted from an attachment
+	maxTemplateSize = 4096
+	// Largest rendered template, which is the longest message discord accepts
+	maxRenderedSize = 2000
+)
+
+var defaultTemplateTexts = map[string]string{
+	sessionTemplateName: `
+**{{ .Username }}**{{with .Account}} ({{.}}){{end}}:
+session length: {{if (gt .Hours 0)}}{{ .Hours }} {{if (eq .Hours 1)}}hr{{else}}hrs{{end}} {{end}}{{ .Minutes }} min{{if not .IsEmptyQuickplay}}
+quickplay: {{.QuickplayWDL.Win}} {{if (eq .QuickplayWDL.Win 1)}}win{{else}}wins{{end}}, {{.QuickplayWDL.Loss}} {{if (eq .QuickplayWDL.Loss 1)}}loss{{else}}losses{{end}}{{end}}{{if .HasQuickplayWins}}
+quickplay wins: {{.QuickplayWinString}}{{end}}{{if .HasQuickplayLosses}}
+quickplay losses: {{.QuickplayLossString}}{{end}}{{if .HasMatchTimeline}}
+comp games: {{.MatchTimeline}}{{else}}{{if .HasWins}}
+comp wins: {{.WinString}}{{end}}{{if .HasDraws}}
+comp draws: {{.DrawString}}{{end}}{{if .HasLosses}}
+comp losses: {{.LossString}}{{end}}{{end}}{{if .HasRoles}}
+roles: {{range $i, $role := .Roles}}{{if $i}}, {{end}}{{$role.Role}} {{$role.WDL.Win}}W{{if $role.WDL.Draw}} {{$role.WDL.Draw}}D{{end}} {{$role.WDL.Loss}}L{{if $role.SRKnown}} ({{signed $role.SRDiff}}){{end}}{{end}}{{end}}{{if .HasPerformance}}{{with .Performance}}
+per game: {{printf "%.1f" .EliminationsPerGame}} elims, {{printf "%.1f" .DeathsPerGame}} deaths, KPD {{printf "%.2f" .KPD}} (season {{printf "%.2f" .CareerKPD}}){{if gt .Medals 0.0}}
+medals: {{printf "%.0f" .MedalsGold}} gold, {{printf "%.0f" .MedalsSilver}} silver, {{printf "%.0f" .MedalsBronze}} bronze{{end}}{{end}}{{end}}{{if .HasSRChange}}
+SR: {{with .TierIcon}}{{.}} {{end}}{{ .FinalSR }} ({{if (ge .SRDiff 0)}}+{{end}}{{ .SRDiff }}){{with .TierChange}}, {{.}}{{end}}{{else if .HasPlaced}}
+placed at {{with .TierIcon}}{{.}} {{end}}{{ .FinalSR }} SR ({{ .FinalTier }}){{else if .IsPlacement}}
+placement matches in progress{{end}}
+`,
+	noChangeTemplateName: `
+**{{ .Username }}**{{with .Account}} ({{.}}){{end}}: SR {{with .TierIcon}}{{.}} {{end}}{{ .FinalSR }}{{with .FinalTier}} ({{.}}){{end}}
+`,
+}
+
+func plural(count int, singular string, plural string) string {
+	if count == 1 {
+		return singular
+	}
+	return plural
+}
+
+// Helper functions available to every template
+var templateFuncs = template.FuncMap{
+	// plural 2 "win" "wins" => "wins"
+	"plural": plural,
+	// signed 5 => "+5", signed -5 => "-5"
+	"signed": func(value int) string {
+		if value >= 0 {
+			return fmt.Sprintf("+%d", value)
+		}
+		return fmt.Sprintf("%d", value)
+	},
+	// percent 1 3 => 33
+	"percent": func(part int, total int) int {
+		if total == 0 {
+			return 0
+		}
+		return part * 100 / total
+	},
+	"heroName": func(heroId string) string {
+		if hero, ok := overwatch.GetHero(heroId); ok {
+			return hero.Name
+		}
+		return heroId
+	},
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+}
+
+// Start of the sample session
+var sampleTime = time.Date(2017, time.June, 1, 19, 0, 0, 0, time.UTC)
+
+// Data used to validate and preview templates
+func samplePlayerSessionData() playerSessionData {
+	return playerSessionData{
+		Username:  "Sample",
+		BattleTag: "Sample#1234",
+		InitialSR: 2480,
+		FinalSR:   2503,
+		SRDiff:    23,
+		Hours:     1,
+		Minutes:   25,
+		HeroesWDL: map[string]overwatch.WDL{
+			"mercy": {Win: 2, Draw: 0, Loss: 1},
+			"lucio": {Win: 1, Draw: 1, Loss: 0},
+		},
+		QuickplayWDL: overwatch.WDL{Win: 3, Draw: 0, Loss: 2},
+		QuickplayHeroesWDL: map[string]overwatch.WDL{
+			"mercy": {Win: 2, Draw: 0, Loss: 0},
+			"ana":   {Win: 1, Draw: 0, Loss: 0},
+		},
+		Matches: []overwatch.Match{
+			{Time: sampleTime.Add(20 * time.Minute), Result: overwatch.MatchWin, Heroes: []string{"mercy"}, SRDiff: 24, SRKnown: true},
+			{Time: sampleTime.Add(40 * time.Minute), Result: overwatch.MatchLoss, Heroes: []string{"mercy"}, SRDiff: -25, SRKnown: true},
+			{Time: sampleTime.Add(60 * time.Minute), Result: overwatch.MatchDraw, Heroes: []string{"lucio"}, SRDiff: 0, SRKnown: true},
+			{Time: sampleTime.Add(85 * time.Minute), Result: overwatch.MatchWin, Heroes: []string{"mercy", "lucio"}},
+			{Time: sampleTime.Add(85 * time.Minute), Result: overwatch.MatchWin, Heroes: []string{"mercy", "lucio"}},
+		},
+		Performance: overwatch.GameStatsDiff{
+			Games:        4,
+			Eliminations: 71,
+			Deaths:       29,
+			SoloKills:    6,
+			Medals:       9,
+			MedalsGold:   3,
+			MedalsSilver: 4,
+			MedalsBronze: 2,
+			TimePlayed:   1.2,
+			CareerKPD:    2.1,
+		},
+	}
+}
+
+// Sample data rendered with the guild's emoji
+func (bot *Bot) getSamplePlayerSessionData() playerSessionData {
+	sessionData := samplePlayerSessionData()
+	sessionData.HeroEmojis = bot.getHeroEmojis()
+	sessionData.TierEmojis = bot.getTierEmojis()
+	return sessionData
+}
+
+// Parses a template and validates it by executing it against sample data
+func parseTemplate(name string, text string) (*template.Template, error) {
+	tmpl, err := template.New(name).Funcs(templateFuncs).Parse(strings.TrimSpace(text))
+	if err != nil {
+		return nil, err
+	}
+
+	message, err := executeTemplate(tmpl, samplePlayerSessionData())
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(message) == "" {
+		return nil, errors.New("template renders an empty message")
+	}
+
+	return tmpl, nil
+}
+
+var errRenderedTooLong = fmt.Errorf("template renders more than %d characters", maxRenderedSize)
+
+// A buffer that refuses writes past maxRenderedSize, which stops a template
+// from rendering more than fits in a message
+type limitedBuffer struct {
+	bytes.Buffer
+}
+
+func (buffer *limitedBuffer) Write(p []byte) (int, error) {
+	if buffer.Len()+len(p) > maxRenderedSize {
+		return 0, errRenderedTooLong
+	}
+	return buffer.Buffer.Write(p)
+}
+
+// Executes the template, failing if it renders more than maxRenderedSize
+func executeTemplate(tmpl *template.Template, data interface{}) (string, error) {
+	var message limitedBuffer
+	if err := tmpl.Execute(&message, data); err != nil {
+		return "", err
+	}
+	return message.String(), nil
+}
+
+// Loads the configured template files, falling back to the built-in
+// template for any name not configured. Returns the template texts by name.
+func loadTemplateFiles(templateFiles map[string]string) (map[string]string, error) {
+	templateTexts := make(map[string]string)
+	for name, text := range defaultTemplateTexts {
+		templateTexts[name] = text
+	}
+
+	for name, file := range templateFiles {
+		if _, ok := defaultTemplateTexts[name]; !ok {
+			return nil, fmt.Errorf("unknown template name %s", name)
+		}
+
+		text, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := parseTemplate(name, string(text)); err != nil {
+			return nil, fmt.Errorf("template file %s: %v", file, err)
+		}
+		templateTexts[name] = string(text)
+	}
+
+	return templateTexts, nil
+}
+
+// Returns the text of the template used by the guild, which is the guild's
+// own template if set, or else the configured default.
+func (bot *Bot) getTemplateText(name string) string {
+	bot.mutex.RLock()
+	text := bot.templateTexts[name]
+	bot.mutex.RUnlock()
+
+	settings, err := bot.store.GetGuildSettings(bot.discord.GetGuildId())
+	if err != nil {
+		bot.logger.WithError(err).Error("could not get guild settings")
+		return text
+	}
+
+	if guildText, ok := settings.Templates[name]; ok {
+		return guildText
+	}
+	return text
+}
+
+func (bot *Bot) getTemplate(name string) *template.Template {
+	tmpl, err := parseTemplate(name, bot.getTemplateText(name))
+	if err != nil {
+		bot.logger.WithError(err).WithField("template", name).Error("invalid template, using built-in")
+		return template.Must(parseTemplate(name, defaultTemplateTexts[name]))
+	}
+	return tmpl
+}
+
+var regexWhitespace = regexp.MustCompile(`\s+`)
+
+const templateUsage = "usage: `!template list|fields|show <name>|preview <name> [text]|set <name> <text or attached file>|reset <name>`"
+
+// Handles the !template command. Changing templates and previewing new
+// template text requires the user to be a guild admin.
+func (bot *Bot) templateCommand(message *discordgo.Message, args string) {
+	bot.logger.WithField("user", message.Author).WithField("args", args).Info("template request")
+
+	input := regexWhitespace.Split(strings.TrimSpace(args), 3)
+	subCommand := input[0]
+	if subCommand == "list" {
+		bot.listTemplates()
+		return
+	}
+	if subCommand == "fields" {
+		bot.discord.CreateMessage(templateFieldsHelp)
+		return
+	}
+	if len(input) < 2 {
+		bot.discord.CreateMessage(templateUsage)
+		return
+	}
+
+	name := input[1]
+	if _, ok := defaultTemplateTexts[name]; !ok {
+		bot.discord.CreateMessage(name + " is not a template, try `!template list`")
+		return
+	}
+
+	var text string
+	if len(input) == 3 {
+		text = input[2]
+	}
+
+	switch subCommand {
+	case "show":
+		bot.discord.CreateMessage(fmt.Sprintf("template %s:\n```\n%s\n```", name, strings.TrimSpace(bot.getTemplateText(name))))
+	case "preview":
+		tmpl := bot.getTemplate(name)
+		if text != "" {
+			if !bot.hasPermission(message, actionPreviewTemplates) {
+				return
+			}
+			var err error
+			if tmpl, err = parseTemplate(name, text); err != nil {
+				bot.discord.CreateMessage("invalid template: " + err.Error())
+				return
+			}
+		}
+		bot.discord.CreateMessage(bot.getTemplateMessage(tmpl, bot.getSamplePlayerSessionData()))
+	case "set":
+		if !bot.hasPermission(message, actionChangeTemplates) {
+			return
+		}
+		if text == "" && len(message.Attachments) > 0 {
+			var err error
+			if text, err = bot.readAttachment(message.Attachments[0]); err != nil {
+				bot.logger.WithError(err).Error("could not read template attachment")
+				bot.discord.CreateMessage("could not read the attached template")
+				return
+			}
+		}
+		if text == "" {
+			bot.discord.CreateMessage(templateUsage)
+			return
+		}
+		bot.setGuildTemplate(name, text)
+	case "reset":
+		if !bot.hasPermission(message, actionChangeTemplates) {
+			return
+		}
+		bot.setGuildTemplate(name, "")
+	default:
+		bot.discord.CreateMessage(templateUsage)
+	}
+}
+
+func (bot *Bot) listTemplates() {
+	settings, err := bot.store.GetGuildSettings(bot.discord.GetGuildId())
+	if err != nil {
+		bot.logger.WithError(err).Error("could not get guild settings")
+		return
+	}
+
+	var names []string
+	for name := range defaultTemplateTexts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buffer bytes.Buffer
+	buffer.WriteString("templates:")
+	for _, name := range names {
+		buffer.WriteString("\n" + name)
+		if _, ok := settings.Templates[name]; ok {
+			buffer.WriteString(" (customized)")
+		}
+	}
+	bot.discord.CreateMessage(buffer.String())
+}
+
+// Validates and stores a guild template. An empty text resets the template
+// to the default.
+func (bot *Bot) setGuildTemplate(name string, text string) {
+	if text != "" {
+		if _, err := parseTemplate(name, text); err != nil {
+			bot.discord.CreateMessage("invalid template: " + err.Error())
+			return
+		}
+	}
+
+	guildId := bot.discord.GetGuildId()
+	settings, err := bot.store.GetGuildSettings(guildId)
+	if err != nil {
+		bot.logger.WithError(err).Error("could not get guild settings")
+		return
+	}
+
+	if text == "" {
+		delete(settings.Templates, name)
+	} else {
+		settings.Templates[name] = text
+	}
+
+	if err := bot.store.PutGuildSettings(guildId, settings); err != nil {
+		bot.logger.WithError(err).Error("could not store guild settings")
+		bot.discord.CreateMessage("could not save template " + name)
+		return
+	}
+
+	if text == "" {
+		bot.discord.CreateMessage("template " + name + " reset to default")
+	} else {
+		bot.discord.CreateMessage("template " + name + " updated, preview:\n" + bot.getTemplateMessage(bot.getTemplate(name), bot.getSamplePlayerSessionData()))
+	}
+}
+
+func (bot *Bot) readAttachment(attachment *discordgo.MessageAttachment) (string, error) {
+	if attachment.Size > maxTemplateSize {
+		return "", fmt.Errorf("attachment of %d bytes is too large", attachment.Size)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), bot.getConfig().Polling.CommandTimeout)
+	defer cancel()
+
+	req, err := http.NewRequest("GET", attachment.URL, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	text, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxTemplateSize))
+	if err != nil {
+		return "", err
+	}
+	return string(text), nil
+}
+
+const templateFieldsHelp = "template fields: `.Username .BattleTag .InitialSR .FinalSR .SRDiff .Hours .Minutes .HeroesWDL .QuickplayWDL .QuickplayHeroesWDL .NewSeason .Performance .Matches .Account .Alt .HeroEmojis .TierEmojis`\n" +
+	"methods: `.HasSRChange .IsPlacement .HasPlaced .InitialTier .FinalTier .TierIcon .TierChange .TierProgress .HasPerformance .Performance.EliminationsPerGame .Performance.DeathsPerGame .Performance.KPD .HasWins .HasDraws .HasLosses .WinString .DrawString .LossString .HasMatchTimeline .MatchTimeline .HasRoles .Roles .IsEmptyQuickplay .HasQuickplayWins .HasQuickplayLosses .QuickplayWinString .QuickplayLossString .CompWDL .CompGames .Heroes .Emoji <hero>`\n" +
+	"functions: `plural <n> <singular> <plural>`, `signed <n>`, `percent <part> <total>`, `heroName <hero>`, `upper`, `lower`"
@@ -0,0 +1,161 @@
+package owbot
+
+import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/snakelayer/discord-oversessions/owbot/overwatch"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+// Sample data with emoji for some heroes and tiers, so that reports mix
+// emoji and hero abbreviations
+func testSessionData() playerSessionData {
+	sessionData := samplePlayerSessionData()
+	sessionData.HeroEmojis = map[string]string{"mercy": "<:mercy:1>"}
+	sessionData.TierEmojis = map[string]string{"platinum": "<:plat:2>"}
+	return sessionData
+}
+
+func TestDefaultTemplates(t *testing.T) {
+	// matches that all ended between the same two snapshots have no
+	// timeline, so the report lists them by result instead
+	altSessionData := testSessionData()
+	altSessionData.Account = "smurf#1234"
+	altSessionData.Alt = true
+	for i := range altSessionData.Matches {
+		altSessionData.Matches[i].Time = sampleTime.Add(85 * time.Minute)
+	}
+
+	tests := []struct {
+		golden   string
+		template string
+		data     playerSessionData
+	}{
+		{"session", sessionTemplateName, testSessionData()},
+		{"session_alt", sessionTemplateName, altSessionData},
+		{"nochange", noChangeTemplateName, testSessionData()},
+	}
+
+	for _, test := range tests {
+		tmpl, err := parseTemplate(test.template, defaultTemplateTexts[test.template])
+		if err != nil {
+			t.Fatalf("%s: %v", test.golden, err)
+		}
+		message, err := executeTemplate(tmpl, test.data)
+		if err != nil {
+			t.Fatalf("%s: %v", test.golden, err)
+		}
+
+		file := filepath.Join("testdata", test.golden+".golden")
+		if *update {
+			if err := ioutil.WriteFile(file, []byte(message), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		golden, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if message != string(golden) {
+			t.Errorf("%s: got\n%s\nwant\n%s", test.golden, message, golden)
+		}
+	}
+}
+
+func TestWinLossString(t *testing.T) {
+	tests := []struct {
+		heroesWDL map[string]overwatch.WDL
+		win       string
+		loss      string
+	}{
+		{nil, "", ""},
+		{map[string]overwatch.WDL{"mercy": {Win: 2}}, "<:mercy:1><:mercy:1>", ""},
+		// heroes with more games come first, whatever the result
+		{map[string]overwatch.WDL{"ana": {Win: 1}, "mercy": {Win: 1, Loss: 2}}, "<:mercy:1>[Ana]", "<:mercy:1><:mercy:1>"},
+		// ties are ordered by role, then by name
+		{map[string]overwatch.WDL{"mercy": {Loss: 1}, "reinhardt": {Loss: 1}, "ana": {Loss: 1}}, "", "[Rein][Ana]<:mercy:1>"},
+		// draws show in neither
+		{map[string]overwatch.WDL{"lucio": {Draw: 1}, "mercy": {Win: 1}}, "<:mercy:1>", ""},
+		{map[string]overwatch.WDL{"unknown": {Win: 1}}, "[unknown]", ""},
+	}
+
+	for _, test := range tests {
+		sessionData := testSessionData()
+		sessionData.HeroesWDL = test.heroesWDL
+		if win := sessionData.WinString(); win != test.win {
+			t.Errorf("WinString of %v = %q, want %q", test.heroesWDL, win, test.win)
+		}
+		if loss := sessionData.LossString(); loss != test.loss {
+			t.Errorf("LossString of %v = %q, want %q", test.heroesWDL, loss, test.loss)
+		}
+	}
+}
+
+func TestMatchTimeline(t *testing.T) {
+	at := func(minutes int) time.Time {
+		return sampleTime.Add(time.Duration(minutes) * time.Minute)
+	}
+
+	tests := []struct {
+		matches     []overwatch.Match
+		hasTimeline bool
+		timeline    string
+	}{
+		{nil, false, ""},
+		{
+			[]overwatch.Match{{Time: at(20), Result: overwatch.MatchWin, Heroes: []string{"mercy"}, SRDiff: 24, SRKnown: true}},
+			true, "W (+24) Mercy",
+		},
+		// matches are listed in the order they were played
+		{
+			[]overwatch.Match{
+				{Time: at(20), Result: overwatch.MatchLoss, Heroes: []string{"ana"}, SRDiff: -26, SRKnown: true},
+				{Time: at(40), Result: overwatch.MatchWin, Heroes: []string{"mercy"}, SRDiff: 25, SRKnown: true},
+			},
+			true, "L (-26) Ana, W (+25) Mercy",
+		},
+		// draws, and matches whose SR change is unknown
+		{
+			[]overwatch.Match{
+				{Time: at(20), Result: overwatch.MatchDraw, Heroes: []string{"lucio"}, SRKnown: true},
+				{Time: at(40), Result: overwatch.MatchDraw, Heroes: []string{"lucio"}},
+				{Time: at(60), Result: overwatch.MatchWin},
+			},
+			true, "D (+0) Lúcio, D Lúcio, W",
+		},
+		// several matches between the same snapshots list all their heroes
+		{
+			[]overwatch.Match{
+				{Time: at(20), Result: overwatch.MatchWin, Heroes: []string{"mercy"}, SRDiff: 20, SRKnown: true},
+				{Time: at(45), Result: overwatch.MatchWin, Heroes: []string{"mercy", "unknown"}},
+				{Time: at(45), Result: overwatch.MatchLoss, Heroes: []string{"ana"}},
+			},
+			true, "W (+20) Mercy, W Mercy/unknown, L Ana",
+		},
+		// the order of matches that all ended between the same snapshots
+		// is unknown
+		{
+			[]overwatch.Match{
+				{Time: at(45), Result: overwatch.MatchWin, Heroes: []string{"mercy"}},
+				{Time: at(45), Result: overwatch.MatchLoss, Heroes: []string{"mercy"}},
+			},
+			false, "W Mercy, L Mercy",
+		},
+	}
+
+	for _, test := range tests {
+		sessionData := testSessionData()
+		sessionData.Matches = test.matches
+		if hasTimeline := sessionData.HasMatchTimeline(); hasTimeline != test.hasTimeline {
+			t.Errorf("HasMatchTimeline of %v = %v, want %v", test.matches, hasTimeline, test.hasTimeline)
+		}
+		if timeline := sessionData.MatchTimeline(); timeline != test.timeline {
+			t.Errorf("MatchTimeline of %v = %q, want %q", test.matches, timeline, test.timeline)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package owbot
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/snakelayer/discord-oversessions/owbot/overwatch"
+	"github.com/snakelayer/discord-oversessions/owbot/store"
+)
+
+// Picks the heroes of verifications. A rand.Rand is not safe for concurrent
+// use, so it is guarded by challengeMutex.
+var (
+	challengeMutex sync.Mutex
+	challengeRand  = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+// Starts verifying that the author owns the battleTag, instead of linking
+// them, or adding it as an alt account if alt is set, right away. The
+// author is asked to play a game on a hero they have barely played on that
+// account, which is confirmed with !verify once the stats show more time
+// on it.
+func (bot *Bot) startLinkVerification(message *discordgo.Message, battleTag string, alt bool) {
+	user := message.Author
+	bot.logger.WithField("user", user).WithField("battleTag", battleTag).WithField("alt", alt).Info("link verification request")
+
+	if playerState, ok := bot.getPlayerState(user.ID); ok && !alt && playerState.BattleTag == battleTag {
+		bot.reply(message, user.Username+" is already linked to "+battleTag)
+		return
+	}
+	regionBlob, ok := bot.getLinkableBlob(battleTag)
+	if !ok {
+		return
+	}
+
+	if deleted, err := bot.store.DeleteExpiredVerifications(time.Now()); err != nil {
+		bot.logger.WithError(err).Error("could not delete expired verifications")
+	} else if deleted > 0 {
+		bot.logger.WithField("verifications", deleted).Info("deleted expired verifications")
+	}
+
+	hero := pickChallengeHero(regionBlob)
+	expiry := bot.getConfig().Guild.VerificationExpiry
+	verification := store.Verification{
+		UserId:     user.ID,
+		BattleTag:  battleTag,
+		HeroId:     hero.Id,
+		TimePlayed: heroTimePlayed(regionBlob, hero.Id),
+		Expires:    time.Now().Add(expiry),
+		Alt:        alt,
+	}
+	if err := bot.store.PutVerification(verification); err != nil {
+		bot.logger.WithError(err).WithField("userId", user.ID).Error("could not store verification")
+		bot.reply(message, "could not link "+user.Username+" to "+battleTag)
+		return
+	}
+
+	bot.reply(message, fmt.Sprintf("%s: to prove %s is yours, play a game on **%s** with it within %s, then use `!verify`",
+		user.Username, battleTag, hero.Name, formatHoursMinutes(expiry)))
+}
+
+// Picks a random hero among those with the least time played on the
+// account, so that a game on it is easy to tell apart from the account's
+// usual play
+func pickChallengeHero(regionBlob *overwatch.RegionBlob) overwatch.Hero {
+	var candidates []overwatch.Hero
+	var least float32
+	for _, hero := range overwatch.Heroes {
+		timePlayed := heroTimePlayed(regionBlob, hero.Id)
+		if len(candidates) == 0 || timePlayed < least {
+			candidates = []overwatch.Hero{hero}
+			least = timePlayed
+		} else if timePlayed == least {
+			candidates = append(candidates, hero)
+		}
+	}
+
+	challengeMutex.Lock()
+	defer challengeMutex.Unlock()
+	return candidates[challengeRand.Intn(len(candidates))]
+}
+
+// Handles the !verify command, linking the author to the battleTag of their
+// pending verification once its stats show they played the picked hero
+func (bot *Bot) verifyCommand(message *discordgo.Message) {
+	user := message.Author
+	bot.logger.WithField("user", user).Info("verify request")
+
+	verification, err := bot.store.GetVerification(user.ID)
+	if err != nil {
+		bot.logger.WithError(err).WithField("userId", user.ID).Error("could not get verification")
+		bot.reply(message, "could not verify, please try again")
+		return
+	}
+	if verification == nil {
+		bot.reply(message, "nothing to verify, use `!link <battleTag>` first")
+		return
+	}
+	if verification.IsExpired(time.Now()) {
+		if err := bot.store.DeleteVerification(user.ID); err != nil {
+			bot.logger.WithError(err).WithField("userId", user.ID).Error("could not delete verification")
+		}
+		bot.reply(message, "your verification of "+verification.BattleTag+" expired, use `!link` to start again")
+		return
+	}
+
+	hero, _ := overwatch.GetHero(verification.HeroId)
+	ctx, cancel := context.WithTimeout(context.Background(), bot.getConfig().Polling.LongCommandTimeout)
+	defer cancel()
+	regionBlob, err := bot.overwatch.GetUSPlayerBlob(ctx, verification.BattleTag)
+	if err != nil {
+		bot.logger.WithError(err).WithField("battleTag", verification.BattleTag).Error("could not get stats")
+		bot.reply(message, "could not get the stats of "+verification.BattleTag+", please try again")
+		return
+	}
+	if !isChallengeDone(*verification, regionBlob) {
+		bot.reply(message, fmt.Sprintf("no game on **%s** seen on %s yet. Stats can take a few minutes to update after a game",
+			hero.Name, verification.BattleTag))
+		return
+	}
+
+	if verification.Alt {
+		if err := bot.addPlayerAlt(user.ID, verification.BattleTag); err != nil {
+			bot.logger.WithError(err).Error("could not store alt account")
+			bot.reply(message, "could not add "+verification.BattleTag+" to "+user.Username+"'s accounts")
+			return
+		}
+	} else if err := bot.linkPlayer(user.ID, verification.BattleTag, store.LinkSourceCommand); err != nil {
+		bot.logger.WithError(err).Error("could not store link")
+		bot.reply(message, "could not link "+user.Username+" to "+verification.BattleTag)
+		return
+	}
+	if err := bot.store.DeleteVerification(user.ID); err != nil {
+		bot.logger.WithError(err).WithField("userId", user.ID).Error("could not delete verification")
+	}
+	bot.logger.WithField("userId", user.ID).WithField("battleTag", verification.BattleTag).Info("verified link")
+
+	if verification.Alt {
+		bot.reply(message, user.Username+" is verified and "+verification.BattleTag+" is added as an alt account")
+		return
+	}
+	bot.reply(message, user.Username+" is verified and now linked to "+verification.BattleTag)
+}
+
+// Whether the stats show more time on the verification's hero than when it
+// started
+func isChallengeDone(verification store.Verification, regionBlob *overwatch.RegionBlob) bool {
+	return heroTimePlayed(regionBlob, verification.HeroId) > verification.TimePlayed
+}
+
+// Returns the hours played on the hero in comp and quickplay
+func heroTimePlayed(regionBlob *overwatch.RegionBlob, heroId string) float32 {
+	var hours float32
+	if heroStats := regionBlob.GetAllHeroStats().HeroStats(heroId); heroStats != nil {
+		hours += heroStats.GeneralStats.TimePlayed
+	}
+	if heroStats := regionBlob.GetQuickplayHeroStats().HeroStats(heroId); heroStats != nil {
+		hours += heroStats.GeneralStats.TimePlayed
+	}
+	return hours
+}
@@ -0,0 +1,158 @@
+package owbot
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/snakelayer/discord-oversessions/owbot/overwatch"
+	"github.com/snakelayer/discord-oversessions/owbot/store"
+)
+
+// Returns a blob with the given hours played by hero id, in comp and in
+// quickplay
+func heroTimeBlob(t *testing.T, comp map[string]float32, quickplay map[string]float32) *overwatch.RegionBlob {
+	heroStats := func(hours map[string]float32) map[string]interface{} {
+		stats := make(map[string]interface{})
+		for heroId, timePlayed := range hours {
+			stats[heroId] = map[string]interface{}{"general_stats": map[string]float32{"time_played": timePlayed}}
+		}
+		return stats
+	}
+	data, err := json.Marshal(map[string]interface{}{
+		"heroes": map[string]interface{}{
+			"stats": map[string]interface{}{
+				"competitive": heroStats(comp),
+				"quickplay":   heroStats(quickplay),
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var regionBlob overwatch.RegionBlob
+	if err := json.Unmarshal(data, &regionBlob); err != nil {
+		t.Fatal(err)
+	}
+	return &regionBlob
+}
+
+// Returns the hours played for every hero but the excluded ones
+func allHeroesPlayed(hours float32, excluded ...string) map[string]float32 {
+	played := make(map[string]float32)
+	for _, hero := range overwatch.Heroes {
+		played[hero.Id] = hours
+	}
+	for _, heroId := range excluded {
+		delete(played, heroId)
+	}
+	return played
+}
+
+// Returns the hours played with some heroes set to other hours
+func withHours(played map[string]float32, hours map[string]float32) map[string]float32 {
+	for heroId, timePlayed := range hours {
+		played[heroId] = timePlayed
+	}
+	return played
+}
+
+func TestPickChallengeHero(t *testing.T) {
+	tests := []struct {
+		name      string
+		comp      map[string]float32
+		quickplay map[string]float32
+		// the heroes that may be picked, which are all picked eventually
+		want []string
+	}{
+		{"only unplayed hero", allHeroesPlayed(1, "zenyatta"), nil, []string{"zenyatta"}},
+		{"unplayed heroes tie", allHeroesPlayed(1, "ana", "orisa"), nil, []string{"ana", "orisa"}},
+		// comp and quickplay hours add up
+		{
+			"least played over comp and quickplay",
+			withHours(allHeroesPlayed(5), map[string]float32{"mercy": 1, "genji": 1}),
+			map[string]float32{"mercy": 0.8, "genji": 0.2},
+			[]string{"genji"},
+		},
+		{
+			"least played heroes tie",
+			withHours(allHeroesPlayed(2), map[string]float32{"mercy": 0.1, "genji": 0.1}),
+			nil,
+			[]string{"genji", "mercy"},
+		},
+	}
+	for _, test := range tests {
+		regionBlob := heroTimeBlob(t, test.comp, test.quickplay)
+		seen := make(map[string]bool)
+		for i := 0; i < 100; i++ {
+			seen[pickChallengeHero(regionBlob).Id] = true
+		}
+
+		for heroId := range seen {
+			if !contains(test.want, heroId) {
+				t.Errorf("%s: picked %s, want one of %v", test.name, heroId, test.want)
+			}
+		}
+		for _, heroId := range test.want {
+			if !seen[heroId] {
+				t.Errorf("%s: never picked %s in 100 tries", test.name, heroId)
+			}
+		}
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, other := range values {
+		if other == value {
+			return true
+		}
+	}
+	return false
+}
+
+func TestIsChallengeDone(t *testing.T) {
+	verification := store.Verification{HeroId: "mercy", TimePlayed: 1.5}
+
+	tests := []struct {
+		name      string
+		comp      map[string]float32
+		quickplay map[string]float32
+		want      bool
+	}{
+		{"no change", map[string]float32{"mercy": 1}, map[string]float32{"mercy": 0.5}, false},
+		{"quickplay game", map[string]float32{"mercy": 1}, map[string]float32{"mercy": 0.6}, true},
+		{"comp game", map[string]float32{"mercy": 1.1}, map[string]float32{"mercy": 0.5}, true},
+		{"game on another hero", map[string]float32{"mercy": 1, "ana": 3}, map[string]float32{"mercy": 0.5}, false},
+		{"hero missing from the stats", nil, nil, false},
+		{"less time than before", map[string]float32{"mercy": 1}, nil, false},
+	}
+
+	for _, test := range tests {
+		regionBlob := heroTimeBlob(t, test.comp, test.quickplay)
+		if done := isChallengeDone(verification, regionBlob); done != test.want {
+			t.Errorf("%s: isChallengeDone = %v, want %v", test.name, done, test.want)
+		}
+	}
+}
+
+func TestVerificationIsExpired(t *testing.T) {
+	expires := time.Date(2017, time.June, 2, 19, 0, 0, 0, time.UTC)
+	verification := store.Verification{HeroId: "mercy", Expires: expires}
+
+	tests := []struct {
+		now  time.Time
+		want bool
+	}{
+		{expires.Add(-time.Hour), false},
+		{expires.Add(-time.Nanosecond), false},
+		{expires, true},
+		{expires.Add(time.Hour), true},
+	}
+
+	for _, test := range tests {
+		if expired := verification.IsExpired(test.now); expired != test.want {
+			t.Errorf("IsExpired(%v) = %v, want %v", test.now, expired, test.want)
+		}
+	}
+}
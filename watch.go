@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"time"
+)
+
+// fileWatcher polls files for changes to their modification time. It is
+// not safe for concurrent use, and is driven from the main loop.
+type fileWatcher struct {
+	modTimes map[string]time.Time
+}
+
+func newFileWatcher(files ...string) *fileWatcher {
+	watcher := &fileWatcher{}
+	watcher.setFiles(files...)
+	return watcher
+}
+
+// Replaces the watched files, remembering their current modification times.
+// Empty file names are ignored.
+func (watcher *fileWatcher) setFiles(files ...string) {
+	watcher.modTimes = make(map[string]time.Time)
+	for _, file := range files {
+		if file == "" {
+			continue
+		}
+		watcher.modTimes[file] = modTime(file)
+	}
+}
+
+// Returns a channel that fires after interval, or nil (blocking forever)
+// if watching is disabled.
+func (watcher *fileWatcher) tick(interval time.Duration) <-chan time.Time {
+	if interval <= 0 || len(watcher.modTimes) == 0 {
+		return nil
+	}
+	return time.After(interval)
+}
+
+// Reports whether any watched file changed since the last call.
+func (watcher *fileWatcher) changed() bool {
+	changed := false
+	for file, prevModTime := range watcher.modTimes {
+		nextModTime := modTime(file)
+		if !nextModTime.Equal(prevModTime) {
+			watcher.modTimes[file] = nextModTime
+			changed = true
+		}
+	}
+	return changed
+}
+
+// Returns the modification time of file, or the zero time if it can't be read
+func modTime(file string) time.Time {
+	fileInfo, err := os.Stat(file)
+	if err != nil {
+		return time.Time{}
+	}
+	return fileInfo.ModTime()
+}